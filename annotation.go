@@ -0,0 +1,98 @@
+package connect
+
+import (
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	tracev1 "github.com/cskd8/dd-trace-go-contrib-connect/proto/dd/trace/v1"
+)
+
+// methodOptions returns the dd.trace.v1.method_options annotation of the method
+// described by spec.Schema, or nil when the method is not annotated or the
+// schema is not a protobuf method descriptor.
+func methodOptions(spec connect.Spec) *tracev1.MethodOptions {
+	md, ok := spec.Schema.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil
+	}
+	opts, ok := md.Options().(*descriptorpb.MethodOptions)
+	if !ok || !proto.HasExtension(opts, tracev1.E_MethodOptions) {
+		return nil
+	}
+	mo, _ := proto.GetExtension(opts, tracev1.E_MethodOptions).(*tracev1.MethodOptions)
+	return mo
+}
+
+// applyMethodOptions applies the dd.trace.v1.method_options annotation mo to cfg.
+func (cfg *config) applyMethodOptions(mo *tracev1.MethodOptions) {
+	if mo.GetSkip() {
+		cfg.skipTracing = true
+	}
+	if fields := mo.GetTagRequestFields(); len(fields) > 0 {
+		cfg.requestFieldTags = fields
+	}
+	if name := mo.GetResourceName(); name != "" {
+		cfg.resourceNameFunc = func(connect.Spec, connect.AnyRequest) string { return name }
+	}
+}
+
+// withRequestFieldTags tags the span with the request message fields selected
+// by WithRequestFieldTags or the tag_request_fields method annotation.
+func withRequestFieldTags(cfg *config, req any, span *tracer.Span) {
+	if len(cfg.requestFieldTags) == 0 {
+		return
+	}
+	p, ok := req.(proto.Message)
+	if !ok {
+		return
+	}
+	m := p.ProtoReflect()
+	for _, path := range cfg.requestFieldTags {
+		if v, ok := messageField(m, path); ok {
+			span.SetTag(tagRequestField+path, v)
+		}
+	}
+}
+
+// messageField returns the tag value of the field of m at path, a dot-separated
+// list of field names. Enums are reported by name and messages as JSON; lists
+// and maps are not supported.
+func messageField(m protoreflect.Message, path string) (any, bool) {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil || fd.IsList() || fd.IsMap() {
+			return nil, false
+		}
+		v := m.Get(fd)
+		if i < len(names)-1 {
+			if fd.Message() == nil {
+				return nil, false
+			}
+			m = v.Message()
+			continue
+		}
+		switch fd.Kind() {
+		case protoreflect.EnumKind:
+			if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+				return string(ev.Name()), true
+			}
+			return int32(v.Enum()), true
+		case protoreflect.MessageKind, protoreflect.GroupKind:
+			b, err := protojson.Marshal(v.Message().Interface())
+			if err != nil {
+				return nil, false
+			}
+			return string(b), true
+		default:
+			return v.Interface(), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,145 @@
+package connect
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	tracev1 "github.com/cskd8/dd-trace-go-contrib-connect/proto/dd/trace/v1"
+)
+
+// annotatedMethod returns the descriptor of a test method annotated with mo.
+func annotatedMethod(t *testing.T, mo *tracev1.MethodOptions) protoreflect.MethodDescriptor {
+	t.Helper()
+	opts := &descriptorpb.MethodOptions{}
+	proto.SetExtension(opts, tracev1.E_MethodOptions, mo)
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/annotated.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/wrappers.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Service"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Method"),
+				InputType:  proto.String(".google.protobuf.StringValue"),
+				OutputType: proto.String(".google.protobuf.StringValue"),
+				Options:    opts,
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("failed to build file descriptor: %v", err)
+	}
+	return fd.Services().Get(0).Methods().Get(0)
+}
+
+func TestForProcedureMethodOptions(t *testing.T) {
+	md := annotatedMethod(t, &tracev1.MethodOptions{
+		Skip:             true,
+		TagRequestFields: []string{"value"},
+		ResourceName:     "custom",
+	})
+	cfg := new(config)
+	serverDefaults(cfg)
+
+	spec := connect.Spec{Procedure: "/test.Service/Method", Schema: md}
+	resolved := cfg.forProcedure(spec)
+	if resolved == cfg {
+		t.Fatal("expected a dedicated config for the annotated method")
+	}
+	if resolved.isTraced(spec.Procedure) {
+		t.Error("expected skip to disable tracing")
+	}
+	if len(resolved.requestFieldTags) != 1 || resolved.requestFieldTags[0] != "value" {
+		t.Errorf("expected tag_request_fields to be applied, got %v", resolved.requestFieldTags)
+	}
+	if resolved.resourceNameFunc == nil || resolved.resourceNameFunc(spec, nil) != "custom" {
+		t.Error("expected resource_name to be applied")
+	}
+	if !cfg.isTraced(spec.Procedure) {
+		t.Error("expected the base config to be left untouched")
+	}
+
+	// explicit procedure options take precedence over the annotation
+	cfg = new(config)
+	serverDefaults(cfg)
+	WithProcedureOptions(spec.Procedure, WithRequestFieldTags())(cfg)
+	if got := cfg.forProcedure(spec).requestFieldTags; len(got) != 0 {
+		t.Errorf("expected WithProcedureOptions to override the annotation, got %v", got)
+	}
+}
+
+func TestWithRequestFieldTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	cfg := new(config)
+	serverDefaults(cfg)
+	WithRequestFieldTags("name", "source_context.file_name", "missing", "options")(cfg)
+
+	span, _ := tracer.StartSpanFromContext(context.Background(), "test")
+	withRequestFieldTags(cfg, &apipb.Api{
+		Name:          "example.v1.ExampleService",
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "example.proto"},
+	}, span)
+	span.Finish()
+
+	finished := mt.FinishedSpans()[0]
+	if got := finished.Tag(tagRequestField + "name"); got != "example.v1.ExampleService" {
+		t.Errorf("expected connect.request.field.name to be tagged, got %v", got)
+	}
+	if got := finished.Tag(tagRequestField + "source_context.file_name"); got != "example.proto" {
+		t.Errorf("expected nested fields to be tagged, got %v", got)
+	}
+	if got := finished.Tag(tagRequestField + "missing"); got != nil {
+		t.Errorf("expected unknown fields to be skipped, got %v", got)
+	}
+	if got := finished.Tag(tagRequestField + "options"); got != nil {
+		t.Errorf("expected list fields to be skipped, got %v", got)
+	}
+
+	cfg.requestFieldTags = []string{"syntax", "source_context"}
+	span, _ = tracer.StartSpanFromContext(context.Background(), "test")
+	withRequestFieldTags(cfg, &apipb.Api{
+		Syntax:        typepb.Syntax_SYNTAX_PROTO3,
+		SourceContext: &sourcecontextpb.SourceContext{},
+	}, span)
+	span.Finish()
+	finished = mt.FinishedSpans()[1]
+	if got := finished.Tag(tagRequestField + "syntax"); got != "SYNTAX_PROTO3" {
+		t.Errorf("expected enum fields to be tagged by name, got %v", got)
+	}
+	if got := finished.Tag(tagRequestField + "source_context"); got != "{}" {
+		t.Errorf("expected message fields to be tagged as JSON, got %v", got)
+	}
+}
+
+func TestServerInterceptorRequestFieldTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor := NewServerInterceptor(WithRequestFieldTags("value"))
+	next := connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+	req := connect.NewRequest(&wrapperspb.StringValue{Value: "hello"})
+	if _, err := interceptor.WrapUnary(next)(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := mt.FinishedSpans()[0].Tag(tagRequestField + "value"); got != "hello" {
+		t.Errorf("expected connect.request.field.value hello, got %v", got)
+	}
+}
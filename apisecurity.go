@@ -0,0 +1,119 @@
+package connect
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/instrumentation/appsec/emitter/waf/addresses"
+)
+
+// envAPISecurityEnabled is the default of WithAPISecurity, as for dd-trace-go's
+// HTTP integrations.
+const envAPISecurityEnabled = "DD_API_SECURITY_ENABLED"
+
+// WithAPISecurity specifies whether the server interceptor tags sampled unary
+// calls with the API Security schemas of their headers and messages, extracted
+// by the In-App WAF, for the API catalog. It only applies when WithAppSec and
+// the tracer's AppSec are enabled, and defaults to DD_API_SECURITY_ENABLED, or
+// true.
+func WithAPISecurity(enabled bool) ServerOption {
+	return serverOption("WithAPISecurity", func(cfg *config) {
+		cfg.apiSecurity = enabled
+	})
+}
+
+// apiSecuritySampleInterval is the interval between two schema extractions for
+// the same procedure and code, as for dd-trace-go's HTTP integrations.
+const apiSecuritySampleInterval = 30 * time.Second
+
+// apiSecuritySampler samples schema extractions per endpoint, as dd-trace-go's
+// own sampler, which is internal to its HTTP integrations, does.
+var apiSecuritySampler = newSchemaSampler(apiSecuritySampleInterval)
+
+// maxSampledEndpoints bounds the endpoints a schemaSampler remembers.
+const maxSampledEndpoints = 4096
+
+type schemaSampleKey struct {
+	procedure string
+	code      connect.Code
+}
+
+// schemaSampler keeps at most one schema extraction per key and interval.
+type schemaSampler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	now      func() time.Time
+	last     map[schemaSampleKey]time.Time
+}
+
+func newSchemaSampler(interval time.Duration) *schemaSampler {
+	return &schemaSampler{
+		interval: interval,
+		now:      time.Now,
+		last:     make(map[schemaSampleKey]time.Time),
+	}
+}
+
+// sample reports whether the schemas of a call with key should be extracted.
+func (s *schemaSampler) sample(key schemaSampleKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if last, ok := s.last[key]; ok && now.Sub(last) < s.interval {
+		return false
+	}
+	if len(s.last) >= maxSampledEndpoints {
+		for k, last := range s.last {
+			if now.Sub(last) >= s.interval {
+				delete(s.last, k)
+			}
+		}
+		if len(s.last) >= maxSampledEndpoints {
+			return false
+		}
+	}
+	s.last[key] = now
+	return true
+}
+
+// extractSchemas runs the WAF's extract-schema processor on the headers,
+// without cookies, and messages of a sampled unary call, which tags the span
+// with their API Security schemas when the call finishes. resp is nil when the
+// call failed. Streaming calls carry no schemas.
+func (a *appsecCall) extractSchemas(procedure string, req connect.AnyRequest, resp connect.AnyResponse, err error) {
+	if a == nil || !a.apiSecurity || !instr.AppSecEnabled() {
+		return
+	}
+	var code connect.Code
+	if err != nil {
+		code = connect.CodeOf(err)
+	}
+	if !apiSecuritySampler.sample(schemaSampleKey{procedure: procedure, code: code}) {
+		return
+	}
+	b := addresses.NewAddressesBuilder().
+		WithHeadersNoCookies(headersNoCookies(req.Header())).
+		WithRequestBody(req.Any()).
+		ExtractSchema()
+	if resp != nil {
+		b = b.WithResponseHeadersNoCookies(headersNoCookies(resp.Header())).
+			WithResponseBody(resp.Any())
+	}
+	a.op.Run(a.op, b.Build())
+}
+
+// headersNoCookies returns header with lowercase keys and without its cookies.
+func headersNoCookies(header http.Header) map[string][]string {
+	headers := make(map[string][]string, len(header))
+	for k, v := range header {
+		k = strings.ToLower(k)
+		if k == "cookie" || k == "set-cookie" {
+			continue
+		}
+		headers[k] = v
+	}
+	return headers
+}
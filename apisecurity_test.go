@@ -0,0 +1,115 @@
+package connect
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/instrumentation/testutils"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestSchemaSampler(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := newSchemaSampler(time.Minute)
+	s.now = func() time.Time { return now }
+	ok := schemaSampleKey{procedure: "/a.v1.A/Get"}
+	failed := schemaSampleKey{procedure: "/a.v1.A/Get", code: connect.CodeNotFound}
+
+	if !s.sample(ok) || !s.sample(failed) {
+		t.Fatalf("expected the first call of each endpoint to be sampled")
+	}
+	if s.sample(ok) {
+		t.Errorf("expected a call within the interval not to be sampled")
+	}
+	now = now.Add(time.Minute)
+	if !s.sample(ok) {
+		t.Errorf("expected a call after the interval to be sampled")
+	}
+}
+
+// API Security schema tags set by the In-App WAF.
+const (
+	tagSchemaRequestHeaders  = "_dd.appsec.s.req.headers"
+	tagSchemaRequestBody     = "_dd.appsec.s.req.body"
+	tagSchemaResponseHeaders = "_dd.appsec.s.res.headers"
+	tagSchemaResponseBody    = "_dd.appsec.s.res.body"
+)
+
+// callWithSchemas calls a unary handler wrapped by the server interceptor
+// configured with opts and returns the API Security schema tags of its span.
+func callWithSchemas(t *testing.T, mt mocktracer.Tracer, opts ...ServerOption) map[string]any {
+	t.Helper()
+	mt.Reset()
+	call := NewServerInterceptor(opts...).WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		resp := connect.NewResponse(wrapperspb.Bool(true))
+		resp.Header().Set("X-Result", "ok")
+		return resp, nil
+	})
+	req := connect.NewRequest(&apipb.Method{Name: "m"})
+	req.Header().Set("Cookie", "secret=1")
+	req.Header().Set("X-Tenant", "t1")
+	if _, err := call(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tags := make(map[string]any)
+	for _, tag := range []string{tagSchemaRequestHeaders, tagSchemaRequestBody, tagSchemaResponseHeaders, tagSchemaResponseBody} {
+		if v := mt.FinishedSpans()[0].Tag(tag); v != nil {
+			tags[tag] = v
+		}
+	}
+	return tags
+}
+
+func TestAPISecuritySchemas(t *testing.T) {
+	testutils.StartAppSec(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	defer func(s *schemaSampler) { apiSecuritySampler = s }(apiSecuritySampler)
+	apiSecuritySampler = newSchemaSampler(time.Minute)
+
+	for _, tc := range []struct {
+		name string
+		opts []ServerOption
+		want bool
+	}{
+		{name: "appsec disabled", opts: []ServerOption{WithAppSec(false)}},
+		{name: "api security disabled", opts: []ServerOption{WithAppSec(true), WithAPISecurity(false)}},
+		{name: "enabled", opts: []ServerOption{WithAppSec(true)}, want: true},
+		{name: "sampled out", opts: []ServerOption{WithAppSec(true)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tags := callWithSchemas(t, mt, tc.opts...)
+			if !tc.want {
+				if len(tags) != 0 {
+					t.Errorf("expected no schemas, got %v", tags)
+				}
+				return
+			}
+			if len(tags) != 4 {
+				t.Fatalf("expected the 4 schemas, got %v", tags)
+			}
+			if got, _ := tags[tagSchemaRequestHeaders].(string); !strings.Contains(got, `"x-tenant"`) || strings.Contains(got, "cookie") {
+				t.Errorf("expected the request headers without cookies, got %s", got)
+			}
+			if got, _ := tags[tagSchemaRequestBody].(string); !strings.Contains(got, `"name":[8]`) {
+				t.Errorf("expected the request message fields, got %s", got)
+			}
+		})
+	}
+}
+
+func TestAPISecurityWithoutTracerAppSec(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	defer func(s *schemaSampler) { apiSecuritySampler = s }(apiSecuritySampler)
+	apiSecuritySampler = newSchemaSampler(time.Minute)
+
+	if tags := callWithSchemas(t, mt, WithAppSec(true)); len(tags) != 0 {
+		t.Errorf("expected no schemas while AppSec is disabled in the tracer, got %v", tags)
+	}
+}
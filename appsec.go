@@ -0,0 +1,126 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/DataDog/dd-trace-go/v2/instrumentation/appsec/emitter/grpcsec"
+	"github.com/DataDog/dd-trace-go/v2/instrumentation/appsec/emitter/waf/actions"
+)
+
+// envAppSecEnabled enables dd-trace-go's Application Security Management, and
+// the WAF monitoring of connect handlers along with it.
+const envAppSecEnabled = "DD_APPSEC_ENABLED"
+
+// WithAppSec specifies whether the server interceptor feeds calls to the
+// In-App WAF of dd-trace-go's Application Security Management: the procedure,
+// request headers, peer address and each decoded request message, including
+// streamed messages. It defaults to DD_APPSEC_ENABLED. Calls are only monitored
+// when AppSec is also enabled in the tracer.
+func WithAppSec(enabled bool) ServerOption {
+	return serverOption("WithAppSec", func(cfg *config) {
+		cfg.appsec = enabled
+	})
+}
+
+// WithAppSecBlockingCode sets the code of the error returned for calls blocked
+// by the WAF. It defaults to connect.CodePermissionDenied.
+func WithAppSecBlockingCode(code connect.Code) ServerOption {
+	return serverOption("WithAppSecBlockingCode", func(cfg *config) {
+		cfg.appsecBlockingCode = code
+	})
+}
+
+// appsecCall is the WAF monitoring of a handler call. Its methods are no-ops on
+// a nil *appsecCall, which is used when AppSec is disabled.
+type appsecCall struct {
+	op          *grpcsec.HandlerOperation
+	block       *atomic.Pointer[actions.BlockGRPC]
+	code        connect.Code
+	apiSecurity bool
+}
+
+// startAppSec starts the WAF monitoring of a call to procedure, which runs the
+// WAF on its headers and peer address, and returns the context of the call. It
+// returns a nil *appsecCall when WithAppSec is disabled.
+func startAppSec(
+	ctx context.Context,
+	cfg *config,
+	span *tracer.Span,
+	procedure string,
+	header http.Header,
+	peer connect.Peer,
+) (*appsecCall, context.Context) {
+	if !cfg.appsec {
+		return nil, ctx
+	}
+	ctx, op, block := grpcsec.StartHandlerOperation(ctx, span, grpcsec.HandlerOperationArgs{
+		Method:     procedure,
+		Metadata:   metadata(header),
+		RemoteAddr: peer.Addr,
+	})
+	return &appsecCall{op: op, block: block, code: cfg.appsecBlockingCode, apiSecurity: cfg.apiSecurity}, ctx
+}
+
+// metadata returns header with lowercase keys, as gRPC metadata keys are.
+func metadata(header http.Header) map[string][]string {
+	md := make(map[string][]string, len(header))
+	for k, v := range header {
+		k = strings.ToLower(k)
+		md[k] = append(md[k], v...)
+	}
+	return md
+}
+
+// blocked reports whether the WAF decided to block the call so far, in which
+// case it sets *err to the error to return and tags the span.
+func (a *appsecCall) blocked(err *error) bool {
+	if a == nil {
+		return false
+	}
+	action := a.block.Load()
+	if action == nil {
+		return false
+	}
+	_, blockErr := action.GRPCWrapper()
+	a.setBlocked(err, blockErr)
+	return true
+}
+
+func (a *appsecCall) setBlocked(err *error, blockErr error) {
+	// the WAF tags the span with appsec.blocked
+	*err = connect.NewError(a.code, blockErr)
+}
+
+// monitorRequest runs the WAF on the request message msg and returns the
+// error to return when the call is blocked.
+func (a *appsecCall) monitorRequest(ctx context.Context, msg any) (err error) {
+	if a == nil || a.blocked(&err) {
+		return err
+	}
+	monitorErr := grpcsec.MonitorRequestMessage(ctx, msg)
+	if !a.blocked(&err) && monitorErr != nil {
+		a.setBlocked(&err, monitorErr)
+	}
+	return err
+}
+
+// finish finishes the WAF monitoring of the call and reports whether it was
+// blocked. It replaces *err with the blocking error when the WAF blocked the
+// call while it was handled, e.g. through SetUser.
+func (a *appsecCall) finish(err *error) bool {
+	if a == nil {
+		return false
+	}
+	blocked := a.blocked(err)
+	var code int
+	if *err != nil {
+		code = int(connect.CodeOf(*err))
+	}
+	a.op.Finish(grpcsec.HandlerOperationRes{StatusCode: code})
+	return blocked
+}
@@ -0,0 +1,105 @@
+package connect
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/instrumentation/testutils"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// tagAppSecBlocked is set by the WAF on blocked calls.
+const tagAppSecBlocked = "appsec.blocked"
+
+func TestAppSecBlocking(t *testing.T) {
+	testutils.StartAppSec(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for _, tc := range []struct {
+		name string
+		opts []ServerOption
+		want connect.Code
+	}{
+		{name: "default", opts: []ServerOption{WithAppSec(true)}, want: connect.CodePermissionDenied},
+		{name: "code", opts: []ServerOption{WithAppSec(true), WithAppSecBlockingCode(connect.CodeUnavailable)}, want: connect.CodeUnavailable},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt.Reset()
+			var called bool
+			call := NewServerInterceptor(tc.opts...).WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				called = true
+				return connect.NewResponse(&wrapperspb.StringValue{}), nil
+			})
+			req := connect.NewRequest(&wrapperspb.StringValue{})
+			req.Header().Set("dd-canary", "dd-test-scanner-log-block")
+			resp, err := call(context.Background(), req)
+			if got := connect.CodeOf(err); got != tc.want {
+				t.Fatalf("expected code %v, got %v (%v)", tc.want, got, err)
+			}
+			if resp != nil || called {
+				t.Errorf("expected the handler not to be called")
+			}
+
+			spans := mt.FinishedSpans()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 span, got %d", len(spans))
+			}
+			if got := spans[0].Tag(tagAppSecBlocked); got != "true" {
+				t.Errorf("expected %s=true, got %v", tagAppSecBlocked, got)
+			}
+			if got := spans[0].Tag("_dd.appsec.enabled"); got != 1.0 {
+				t.Errorf("expected _dd.appsec.enabled=1, got %v", got)
+			}
+		})
+	}
+}
+
+func TestAppSecDisabled(t *testing.T) {
+	testutils.StartAppSec(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	call := NewServerInterceptor(WithAppSec(false)).WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+	req := connect.NewRequest(&wrapperspb.StringValue{})
+	req.Header().Set("dd-canary", "dd-test-scanner-log-block")
+	if _, err := call(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if got := spans[0].Tag(tagAppSecBlocked); got != nil {
+		t.Errorf("expected no %s tag, got %v", tagAppSecBlocked, got)
+	}
+}
+
+func TestAppSecWithoutWAF(t *testing.T) {
+	t.Setenv(envAppSecEnabled, "true")
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	cfg := newConfig(serverDefaults, nil)
+	if !cfg.appsec || cfg.appsecBlockingCode != connect.CodePermissionDenied {
+		t.Fatalf("expected AppSec enabled by %s with code %v, got %v %v",
+			envAppSecEnabled, connect.CodePermissionDenied, cfg.appsec, cfg.appsecBlockingCode)
+	}
+	// calls go through when the tracer does not run the WAF
+	call := NewServerInterceptor().WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+	req := connect.NewRequest(&wrapperspb.StringValue{})
+	req.Header().Set("dd-canary", "dd-test-scanner-log-block")
+	if _, err := call(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spans := mt.FinishedSpans(); len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+}
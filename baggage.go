@@ -0,0 +1,94 @@
+package connect
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/baggage"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+)
+
+// envBaggageTagKeys is the comma-separated list of baggage keys tagged on server
+// spans as baggage.<key>, or "*" for all of them. It is shared with
+// dd-trace-go's HTTP integrations.
+const envBaggageTagKeys = "DD_TRACE_BAGGAGE_TAG_KEYS"
+
+// defaultBaggageTagKeys are the baggage keys tagged when DD_TRACE_BAGGAGE_TAG_KEYS
+// is unset, as in dd-trace-go's HTTP integrations.
+var defaultBaggageTagKeys = []string{"user.id", "account.id", "session.id"}
+
+// baggageTagKeysFromEnv returns the baggage keys set by DD_TRACE_BAGGAGE_TAG_KEYS,
+// or defaultBaggageTagKeys when it is unset.
+func baggageTagKeysFromEnv() []string {
+	if _, ok := os.LookupEnv(envBaggageTagKeys); !ok {
+		return defaultBaggageTagKeys
+	}
+	return listEnv(envBaggageTagKeys)
+}
+
+// WithBaggageTagKeys sets the baggage keys which the server interceptor tags on
+// spans as baggage.<key>. "*" tags every baggage item, and no keys disables
+// the tags. It defaults to DD_TRACE_BAGGAGE_TAG_KEYS, or to user.id, account.id
+// and session.id.
+func WithBaggageTagKeys(keys ...string) ServerOption {
+	return serverOption("WithBaggageTagKeys", func(cfg *config) {
+		setBaggageTagKeys(cfg, keys)
+	})
+}
+
+func setBaggageTagKeys(cfg *config, keys []string) {
+	cfg.baggageTagKeys = make(map[string]struct{}, len(keys))
+	cfg.allBaggageTags = false
+	for _, k := range keys {
+		if k == "*" {
+			cfg.allBaggageTags = true
+		}
+		cfg.baggageTagKeys[k] = struct{}{}
+	}
+}
+
+func (cfg *config) tagBaggageKey(key string) bool {
+	if cfg.allBaggageTags {
+		return true
+	}
+	_, ok := cfg.baggageTagKeys[key]
+	return ok
+}
+
+// withServerBaggage adds the baggage received with the trace context of the
+// server span to ctx, so handlers can read it with BaggageFromContext, and tags
+// the span with the configured keys.
+func withServerBaggage(cfg *config, ctx context.Context, span *tracer.Span) context.Context {
+	span.Context().ForeachBaggageItem(func(k, v string) bool {
+		ctx = baggage.Set(ctx, k, v)
+		if cfg.tagBaggageKey(k) {
+			span.SetTag(tagBaggagePrefix+k, v)
+		}
+		return true
+	})
+	return ctx
+}
+
+// withClientBaggage sets the baggage carried by ctx on the client span, so it
+// is propagated to the server with the trace context.
+func withClientBaggage(ctx context.Context, span *tracer.Span) {
+	for k, v := range baggage.All(ctx) {
+		span.SetBaggageItem(k, v)
+	}
+}
+
+// BaggageFromContext returns the baggage carried by ctx: in handlers, the
+// baggage received from the client. It is the baggage of dd-trace-go's
+// ddtrace/baggage package.
+func BaggageFromContext(ctx context.Context) map[string]string {
+	return baggage.All(ctx)
+}
+
+// ContextWithBaggage returns a copy of ctx carrying the baggage item key=value,
+// which the client interceptor propagates to the server of the calls made with
+// ctx or a context derived from it, along with the baggage received by the
+// handler. Keys are trimmed of surrounding spaces.
+func ContextWithBaggage(ctx context.Context, key, value string) context.Context {
+	return baggage.Set(ctx, strings.TrimSpace(key), value)
+}
@@ -0,0 +1,91 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestBaggage(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    *string
+		opts   []ServerOption
+		tagged []string
+	}{
+		{name: "default keys", tagged: []string{"user.id"}},
+		{name: "env keys", env: ptr("tenant"), tagged: []string{"tenant"}},
+		{name: "env all", env: ptr("*"), tagged: []string{"user.id", "tenant"}},
+		{name: "env none", env: ptr("")},
+		{name: "option", env: ptr("*"), opts: []ServerOption{WithBaggageTagKeys("tenant")}, tagged: []string{"tenant"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != nil {
+				t.Setenv(envBaggageTagKeys, *tt.env)
+			}
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			var received map[string]string
+			const procedure = "/test.Service/Method"
+			mux := http.NewServeMux()
+			mux.Handle(procedure, connect.NewUnaryHandler(
+				procedure,
+				func(ctx context.Context, req *connect.Request[wrapperspb.StringValue]) (*connect.Response[wrapperspb.StringValue], error) {
+					received = BaggageFromContext(ctx)
+					return connect.NewResponse(&wrapperspb.StringValue{}), nil
+				},
+				connect.WithInterceptors(NewServerInterceptor(tt.opts...)),
+			))
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](
+				srv.Client(),
+				srv.URL+procedure,
+				connect.WithInterceptors(NewClientInterceptor()),
+			)
+			ctx := ContextWithBaggage(context.Background(), "user.id", "u1")
+			ctx = ContextWithBaggage(ctx, "tenant", "acme")
+			if _, err := client.CallUnary(ctx, connect.NewRequest(&wrapperspb.StringValue{})); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if received["user.id"] != "u1" || received["tenant"] != "acme" {
+				t.Errorf("expected the handler to receive the baggage, got %v", received)
+			}
+			var server *mocktracer.Span
+			for _, s := range mt.FinishedSpans() {
+				if s.Tag(ext.SpanKind) == ext.SpanKindServer {
+					server = s
+				}
+			}
+			if server == nil {
+				t.Fatal("expected a server span")
+			}
+			want := map[string]string{"user.id": "u1", "tenant": "acme"}
+			for key, value := range want {
+				got := server.Tag(tagBaggagePrefix + key)
+				tagged := false
+				for _, k := range tt.tagged {
+					tagged = tagged || k == key
+				}
+				if tagged && got != value {
+					t.Errorf("expected tag baggage.%s=%s, got %v", key, value, got)
+				}
+				if !tagged && got != nil {
+					t.Errorf("expected no baggage.%s tag, got %v", key, got)
+				}
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
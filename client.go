@@ -2,6 +2,8 @@ package connect
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 
 	"connectrpc.com/connect"
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
@@ -11,63 +13,91 @@ import (
 var _ connect.Interceptor = (*clientInterceptor)(nil)
 
 type clientInterceptor struct {
-	cfg *config
+	cfg     *atomic.Pointer[config]
+	startup *sync.Once // see logStartup
 }
 
 func (c clientInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	logStartup(c.startup, c)
 	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
 		spec := req.Spec()
-		_, im := c.cfg.ignoredMethods[spec.Procedure]
-		_, um := c.cfg.untracedMethods[spec.Procedure]
-		if im || um {
+		cfg := c.cfg.Load().forProcedure(spec)
+		if !cfg.isTraced(spec.Procedure) {
 			return next(ctx, req)
 		}
-		span, ctx := startSpan(
+		span, ctx := cfg.startCallSpan(
 			ctx,
+			spec,
 			req.Header(),
-			spec.Procedure,
-			c.cfg.spanName,
-			c.cfg.serviceName,
+			req,
+			cfg.spanName,
 			false,
-			c.cfg.startSpanOptions(tracer.Measured(),
-				tracer.Tag(ext.SpanKind, ext.SpanKindClient))...,
+			tracer.Measured(),
+			tracer.Tag(ext.SpanKind, ext.SpanKindClient),
 		)
 		span.SetTag(tagMethodKind, methodKindUnary)
+		withSpecTags(spec, span)
 		withPeerTags(req.Peer(), span)
-		withMetadataTags(c.cfg, req.Header(), span)
-		withRequestTags(c.cfg, req.Any(), span)
+		withMetadataTags(cfg, req.Header(), span)
+		withRequestTags(cfg, req.Any(), span)
+		withRequestFieldTags(cfg, req.Any(), span)
+		for _, fn := range cfg.startHooks {
+			fn(ctx, span, req)
+		}
+		withClientBaggage(ctx, span)
+		withContextTagsBaggage(cfg, ctx, span)
 		// propagate the span context to the server through the request headers
 		_ = tracer.Inject(span.Context(), tracer.HTTPHeadersCarrier(req.Header()))
 		resp, err := next(ctx, req)
-		finishWithError(span, err, c.cfg)
+		withGetTag(req, span)
+		for _, fn := range cfg.finishHooks {
+			fn(ctx, span, resp, err)
+		}
+		finishWithError(span, err, cfg)
 		return resp, err
 	}
 }
 
 // WrapStreamingClient propagates the active span context to the server through
-// the request headers. Streaming client calls are not traced as spans.
+// the request headers, and sets Data Streams Monitoring checkpoints if enabled.
+// Streaming client calls are not traced as spans.
 func (c clientInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	logStartup(c.startup, c)
 	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
 		conn := next(ctx, spec)
 		if span, ok := tracer.SpanFromContext(ctx); ok {
 			_ = tracer.Inject(span.Context(), tracer.HTTPHeadersCarrier(conn.RequestHeader()))
 		}
+		if cfg := c.cfg.Load().forProcedure(spec); cfg.dataStreams && cfg.isTraced(spec.Procedure) {
+			return &dataStreamsClientConn{StreamingClientConn: conn, ctx: ctx}
+		}
 		return conn
 	}
 }
 
 func (c clientInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	logStartup(c.startup, c)
 	return next
 }
 
 // NewClientInterceptor returns a connect.Interceptor which traces unary client
 // calls and injects the trace context into the request headers so the server
-// can continue the trace.
-func NewClientInterceptor(opts ...Option) connect.Interceptor {
-	cfg := new(config)
-	clientDefaults(cfg)
-	for _, opt := range opts {
-		opt(cfg)
+// can continue the trace. Invalid options are ignored; use
+// NewClientInterceptorE to detect them.
+func NewClientInterceptor(opts ...ClientOption) connect.Interceptor {
+	cfg := new(atomic.Pointer[config])
+	cfg.Store(newConfig(clientDefaults, anyOptions(opts)))
+	return &clientInterceptor{cfg: cfg, startup: new(sync.Once)}
+}
+
+// NewClientInterceptorE is like NewClientInterceptor, but returns an error
+// joining every invalid or conflicting option instead of ignoring them.
+func NewClientInterceptorE(opts ...ClientOption) (connect.Interceptor, error) {
+	c := newConfig(clientDefaults, anyOptions(opts))
+	if err := c.validate(); err != nil {
+		return nil, err
 	}
-	return &clientInterceptor{cfg: cfg}
+	cfg := new(atomic.Pointer[config])
+	cfg.Store(c)
+	return &clientInterceptor{cfg: cfg, startup: new(sync.Once)}, nil
 }
@@ -3,8 +3,10 @@ package connect
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"runtime/debug"
 	"strings"
 
 	"connectrpc.com/connect"
@@ -12,28 +14,57 @@ import (
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // cache a constant option: saves one allocation per call
 var spanTypeRPC = tracer.SpanType(ext.AppTypeRPC)
 
+// startSpanOptions returns the span options given to WithSpanOptions and the
+// tags given to WithCustomTag, followed by opts.
 func (cfg *config) startSpanOptions(opts ...tracer.StartSpanOption) []tracer.StartSpanOption {
 	if len(cfg.tags) == 0 && len(cfg.spanOpts) == 0 {
 		return opts
 	}
 
-	ret := make([]tracer.StartSpanOption, 0, 1+len(cfg.tags)+len(opts))
-	ret = append(ret, opts...)
+	ret := make([]tracer.StartSpanOption, 0, len(cfg.spanOpts)+len(cfg.tags)+len(opts))
 	ret = append(ret, cfg.spanOpts...)
 	for key, tag := range cfg.tags {
 		ret = append(ret, tracer.Tag(key, tag))
 	}
+	ret = append(ret, opts...)
 	return ret
 }
 
-// startSpan starts a span for the given method. When extractParent is true,
-// the parent span context is extracted from the incoming request headers
-// (server side); client spans inherit their parent from ctx instead.
+// startCallSpan starts a span for a call to spec, as startNamedSpan does, with
+// the options of cfg followed by opts. The span is named after the service and
+// resource computed by WithServiceNameFunc and WithResourceNameFunc. req is nil
+// for streaming calls and their messages.
+func (cfg *config) startCallSpan(
+	ctx context.Context,
+	spec connect.Spec,
+	headers http.Header,
+	req connect.AnyRequest,
+	operation string,
+	extractParent bool,
+	opts ...tracer.StartSpanOption,
+) (*tracer.Span, context.Context) {
+	service, resource := cfg.serviceName(), spec.Procedure
+	if cfg.serviceNameFunc != nil {
+		if svc := cfg.serviceNameFunc(spec, headers); svc != "" {
+			service = svc
+		}
+	}
+	if cfg.resourceNameFunc != nil {
+		if res := cfg.resourceNameFunc(spec, req); res != "" {
+			resource = res
+		}
+	}
+	return startNamedSpan(ctx, headers, spec.Procedure, operation, service, resource, extractParent, cfg.startSpanOptions(opts...)...)
+}
+
+// startSpan starts a span for the given method, named after it, as
+// startNamedSpan does.
 func startSpan(
 	ctx context.Context,
 	headers http.Header,
@@ -43,33 +74,59 @@ func startSpan(
 	extractParent bool,
 	opts ...tracer.StartSpanOption,
 ) (*tracer.Span, context.Context) {
-	// common stuff
-	opts = append(opts,
-		tracer.ServiceName(serviceFn()),
-		tracer.ResourceName(method),
+	return startNamedSpan(ctx, headers, method, operation, serviceFn(), method, extractParent, opts...)
+}
+
+// startNamedSpan starts a span for the given method with the given service and
+// resource names, tagged with the tags carried by ctx (see ContextWithTags).
+// When extractParent is true, the parent span context is extracted from the
+// incoming request headers (server side); client spans inherit their parent
+// from ctx instead.
+func startNamedSpan(
+	ctx context.Context,
+	headers http.Header,
+	method string,
+	operation string,
+	service string,
+	resource string,
+	extractParent bool,
+	opts ...tracer.StartSpanOption,
+) (*tracer.Span, context.Context) {
+	spanOpts := make([]tracer.StartSpanOption, 0, len(opts)+len(tagsFromContext(ctx))+10)
+	spanOpts = append(spanOpts, opts...)
+	spanOpts = contextTagsOptions(ctx, spanOpts)
+
+	// common stuff, applied last so opts and the context tags cannot override
+	// it; an empty service name lets the span inherit the tracer's service, as
+	// selected by the naming schema
+	if service != "" {
+		spanOpts = append(spanOpts, tracer.ServiceName(service))
+	}
+	spanOpts = append(spanOpts,
+		tracer.ResourceName(resource),
 		tracer.Tag(tagMethodName, method),
 		tracer.Tag(ext.Component, componentName),
 	)
 
 	// gRPC Spec
 	methodElements := strings.SplitN(strings.TrimPrefix(method, "/"), "/", 2)
-	opts = append(opts,
+	spanOpts = append(spanOpts,
 		spanTypeRPC,
 		tracer.Tag(ext.RPCSystem, extRPCSystemConnect),
 		tracer.Tag(ext.GRPCFullMethod, method),
 		tracer.Tag(ext.RPCService, methodElements[0]),
 	)
 	if len(methodElements) > 1 {
-		opts = append(opts, tracer.Tag(ext.RPCMethod, methodElements[1]))
+		spanOpts = append(spanOpts, tracer.Tag(ext.RPCMethod, methodElements[1]))
 	}
 
 	// http Spec
 	if extractParent {
 		if sctx, err := tracer.Extract(tracer.HTTPHeadersCarrier(headers)); err == nil {
-			opts = append(opts, tracer.ChildOf(sctx)) //nolint:staticcheck // SA1019: tracer.ChildOf is deprecated, but kept for compatibility
+			spanOpts = append(spanOpts, tracer.ChildOf(sctx)) //nolint:staticcheck // SA1019: tracer.ChildOf is deprecated, but kept for compatibility
 		}
 	}
-	return tracer.StartSpanFromContext(ctx, operation, opts...)
+	return tracer.StartSpanFromContext(ctx, operation, spanOpts...)
 }
 
 // withMetadataTags tags the span with the request headers, except for the
@@ -117,6 +174,50 @@ func withPeerTags(peer connect.Peer, span *tracer.Span) {
 	}
 }
 
+// withSpecTags tags the span with the idempotency level of the procedure and,
+// when spec.Schema is a protobuf method descriptor, the full names of its
+// request and response message types.
+func withSpecTags(spec connect.Spec, span *tracer.Span) {
+	span.SetTag(tagIdempotency, idempotencyName(spec.IdempotencyLevel))
+	if md, ok := spec.Schema.(protoreflect.MethodDescriptor); ok {
+		span.SetTag(tagRequestType, string(md.Input().FullName()))
+		span.SetTag(tagResponseType, string(md.Output().FullName()))
+	}
+}
+
+// idempotencyName returns the connect.idempotency tag value of level.
+func idempotencyName(level connect.IdempotencyLevel) string {
+	switch level {
+	case connect.IdempotencyNoSideEffects:
+		return idempotencyNoSideEffects
+	case connect.IdempotencyIdempotent:
+		return idempotencyIdempotent
+	}
+	return idempotencyUnknown
+}
+
+// methodKind returns the connect.method.kind tag value of streams of type t.
+func methodKind(t connect.StreamType) string {
+	switch t {
+	case connect.StreamTypeClient:
+		return methodKindClientStream
+	case connect.StreamTypeServer:
+		return methodKindServerStream
+	case connect.StreamTypeBidi:
+		return methodKindBidiStream
+	}
+	return methodKindUnary
+}
+
+// withGetTag tags the span when the unary call was made as a Connect GET
+// request. On the client side, the HTTP method is only known once the request
+// has been sent.
+func withGetTag(req connect.AnyRequest, span *tracer.Span) {
+	if req.HTTPMethod() == http.MethodGet {
+		span.SetTag(tagGet, true)
+	}
+}
+
 // finishWithError applies finish option and a tag with gRPC status code, disregarding OK, EOF and Canceled errors.
 func finishWithError(span *tracer.Span, err error, cfg *config) {
 	if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
@@ -143,3 +244,36 @@ func finishWithError(span *tracer.Span, err error, cfg *config) {
 	}
 	span.Finish(finishOptions...)
 }
+
+// panicError returns the error to report to the client for the recovered
+// panic p.
+func panicError(p any, cfg *config) error {
+	var err error
+	if cfg.panicHandler != nil {
+		err = cfg.panicHandler(p)
+	}
+	if err == nil {
+		err = connect.NewError(connect.CodeInternal, fmt.Errorf("panic: %v", p))
+	}
+	return err
+}
+
+// finishPanic records the recovered panic p, reported to the client as err, on
+// span, when one was started, and finishes it. When WithRepanic is set, p is
+// raised again once the span is finished. It must be called from the deferred
+// function which recovered p so the stack includes the panic site.
+func finishPanic(span *tracer.Span, p any, err error, cfg *config) {
+	if span != nil {
+		span.SetTag(tagCode, connect.CodeOf(err).String())
+		span.SetTag(ext.Error, true)
+		span.SetTag(ext.ErrorType, errorTypePanic)
+		span.SetTag(ext.ErrorMsg, fmt.Sprint(p))
+		if !cfg.noDebugStack {
+			span.SetTag(ext.ErrorStack, string(debug.Stack()))
+		}
+		span.Finish()
+	}
+	if cfg.repanic {
+		panic(p)
+	}
+}
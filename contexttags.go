@@ -0,0 +1,71 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+)
+
+type contextTagsKey struct{}
+
+// ContextWithTags returns a copy of ctx carrying tags, which the interceptors
+// set on the spans they start from ctx or from a context derived from it. It
+// lets layers running before the connect handler, such as an authentication
+// http.Handler, tag the connect span:
+//
+//	ctx := connecttrace.ContextWithTags(r.Context(), map[string]any{"usr.id": user.ID})
+//	next.ServeHTTP(w, r.WithContext(ctx))
+//
+// Tags already carried by ctx are kept unless overridden by tags. They take
+// precedence over the tags given to WithCustomTag, but cannot override the
+// component, span.kind, resource.name and service.name tags of the spans.
+func ContextWithTags(ctx context.Context, tags map[string]any) context.Context {
+	merged := maps.Clone(tagsFromContext(ctx))
+	if merged == nil {
+		merged = make(map[string]any, len(tags))
+	}
+	maps.Copy(merged, tags)
+	return context.WithValue(ctx, contextTagsKey{}, merged)
+}
+
+// tagsFromContext returns the tags carried by ctx. They must not be modified.
+func tagsFromContext(ctx context.Context) map[string]any {
+	tags, _ := ctx.Value(contextTagsKey{}).(map[string]any)
+	return tags
+}
+
+// reservedTags are the tags set by the integration, which the tags carried by a
+// context cannot override.
+var reservedTags = map[string]struct{}{
+	ext.Component:    {},
+	ext.SpanKind:     {},
+	ext.ResourceName: {},
+	ext.ServiceName:  {},
+}
+
+// contextTagsOptions appends to opts the options setting the tags carried by
+// ctx, except for the reserved ones.
+func contextTagsOptions(ctx context.Context, opts []tracer.StartSpanOption) []tracer.StartSpanOption {
+	for key, value := range tagsFromContext(ctx) {
+		if _, ok := reservedTags[key]; ok {
+			continue
+		}
+		opts = append(opts, tracer.Tag(key, value))
+	}
+	return opts
+}
+
+// withContextTagsBaggage sets the tags carried by ctx as baggage items of span,
+// so they are propagated to the server with the trace context, when the
+// WithContextTagsBaggage option is enabled.
+func withContextTagsBaggage(cfg *config, ctx context.Context, span *tracer.Span) {
+	if !cfg.contextTagsBaggage {
+		return
+	}
+	for key, value := range tagsFromContext(ctx) {
+		span.SetBaggageItem(key, fmt.Sprint(value))
+	}
+}
@@ -0,0 +1,99 @@
+package connect
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestContextWithTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ctx := ContextWithTags(context.Background(), map[string]any{"usr.id": "u1", "tenant": "a"})
+	ctx = ContextWithTags(ctx, map[string]any{"tenant": "b", "custom": "from-context"})
+
+	call := NewServerInterceptor(WithCustomTag("custom", "static")).WrapUnary(
+		func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return connect.NewResponse(&wrapperspb.StringValue{}), nil
+		})
+	if _, err := call(ctx, connect.NewRequest(&wrapperspb.StringValue{})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	for key, want := range map[string]string{"usr.id": "u1", "tenant": "b", "custom": "from-context"} {
+		if got := spans[0].Tag(key); got != want {
+			t.Errorf("expected tag %s=%s, got %v", key, want, got)
+		}
+	}
+}
+
+func TestContextWithReservedTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ctx := ContextWithTags(context.Background(), map[string]any{
+		ext.Component:    "custom",
+		ext.SpanKind:     ext.SpanKindProducer,
+		ext.ResourceName: "custom",
+		ext.ServiceName:  "custom",
+	})
+	call := NewServerInterceptor(WithService("svc")).WrapUnary(
+		func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return connect.NewResponse(&wrapperspb.StringValue{}), nil
+		})
+	if _, err := call(ctx, connect.NewRequest(&wrapperspb.StringValue{})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	span := mt.FinishedSpans()[0]
+	for key, want := range map[string]string{
+		ext.Component:   componentName,
+		ext.SpanKind:    ext.SpanKindServer,
+		ext.ServiceName: "svc",
+	} {
+		if got := span.Tag(key); got != want {
+			t.Errorf("expected tag %s=%q, got %v", key, want, got)
+		}
+	}
+	if got := span.Tag(ext.ResourceName); got == "custom" {
+		t.Errorf("expected the resource name not to be overridden, got %v", got)
+	}
+}
+
+func TestContextTagsBaggage(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		mt := mocktracer.Start()
+
+		ctx := ContextWithTags(context.Background(), map[string]any{"usr.id": 42})
+		req := connect.NewRequest(&wrapperspb.StringValue{})
+		call := NewClientInterceptor(WithContextTagsBaggage(enabled)).WrapUnary(
+			func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&wrapperspb.StringValue{}), nil
+			})
+		if _, err := call(ctx, req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		span := mt.FinishedSpans()[0]
+		if got := span.Tag("usr.id"); got != 42.0 { // numeric tags are metrics
+			t.Errorf("expected the client span to be tagged, got %v", got)
+		}
+		got := req.Header().Get("ot-baggage-usr.id")
+		if enabled && got != "42" {
+			t.Errorf("expected the usr.id baggage to be propagated, got headers %v", req.Header())
+		}
+		if !enabled && got != "" {
+			t.Errorf("expected no baggage by default, got %q", got)
+		}
+		mt.Stop()
+	}
+}
@@ -0,0 +1,124 @@
+package connect
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"connectrpc.com/connect"
+)
+
+// newConfig returns a configuration initialized by defaults then opts.
+func newConfig(defaults func(*config), opts []AnyOption) *config {
+	cfg := new(config)
+	defaults(cfg)
+	for _, opt := range opts {
+		cfg.apply(opt)
+	}
+	return cfg
+}
+
+// Control reconfigures a live interceptor returned by
+// NewServerInterceptorWithControl or NewClientInterceptorWithControl, e.g. from
+// an admin endpoint during an incident. Each update builds a new configuration
+// and swaps it in atomically: calls and streams in flight keep the
+// configuration they started with.
+type Control struct {
+	mu       sync.Mutex // serializes updates
+	cfg      *atomic.Pointer[config]
+	defaults func(*config)
+	local    *config  // built from the options and updates, without remote
+	remote   []Option // set by Remote Configuration, applied after local
+}
+
+func newControl(defaults func(*config), opts []AnyOption) *Control {
+	c := &Control{
+		cfg:      new(atomic.Pointer[config]),
+		defaults: defaults,
+		local:    newConfig(defaults, opts),
+	}
+	c.store()
+	return c
+}
+
+// store applies the remote options to a copy of the local configuration and
+// swaps it in. c.mu must be held, except while c is being created.
+func (c *Control) store() {
+	c.cfg.Store(c.local.with(anyOptions(c.remote)))
+}
+
+// with returns a copy of cfg with opts applied on top of the options it was
+// built from. Unlike clone, the copy keeps the procedure options.
+func (cfg *config) with(opts []AnyOption) *config {
+	c := cfg.clone()
+	c.procedureOpts = slices.Clip(cfg.procedureOpts)
+	c.procedureCache = new(sync.Map)
+	for _, opt := range opts {
+		c.apply(opt)
+	}
+	return c
+}
+
+// Update applies opts on top of the options the interceptor currently uses,
+// such as WithRequestTags() or WithUntracedMethods(...). Each update applies
+// opts to a copy of the current configuration, so the Control does not keep
+// them: toggling a setting repeatedly, e.g. with SetRequestTags from an admin
+// endpoint, costs the same every time.
+func (c *Control) Update(opts ...AnyOption) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.local = c.local.with(opts)
+	c.store()
+}
+
+// Reset replaces the options the interceptor uses with opts. Reset with no
+// options restores the defaults. Settings received through Remote
+// Configuration are kept.
+func (c *Control) Reset(opts ...AnyOption) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.local = newConfig(c.defaults, opts)
+	c.store()
+}
+
+// SetEnabled specifies whether the interceptor traces calls, as WithEnabled.
+func (c *Control) SetEnabled(enabled bool) { c.Update(WithEnabled(enabled)) }
+
+// SetStreamCalls specifies whether streaming calls are traced, as
+// WithStreamCalls.
+func (c *Control) SetStreamCalls(enabled bool) { c.Update(WithStreamCalls(enabled)) }
+
+// SetStreamMessages specifies whether streaming messages are traced, as
+// WithStreamMessages.
+func (c *Control) SetStreamMessages(enabled bool) { c.Update(WithStreamMessages(enabled)) }
+
+// SetRequestTags specifies whether request messages are tagged, as
+// WithRequestTags, which cannot disable them.
+func (c *Control) SetRequestTags(enabled bool) { c.Update(requestTags(enabled)) }
+
+// SetMetadataTags specifies whether request metadata is tagged, as
+// WithMetadataTags, which cannot disable it.
+func (c *Control) SetMetadataTags(enabled bool) { c.Update(metadataTags(enabled)) }
+
+// setRemote replaces the options received through Remote Configuration, which
+// are applied on top of the other options.
+func (c *Control) setRemote(opts []Option) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remote = opts
+	c.store()
+}
+
+// NewServerInterceptorWithControl returns a server interceptor, as
+// NewServerInterceptor does, and the Control reconfiguring it at runtime.
+func NewServerInterceptorWithControl(opts ...ServerOption) (connect.Interceptor, *Control) {
+	ctl := newControl(serverDefaults, anyOptions(opts))
+	return &serverInterceptor{cfg: ctl.cfg, startup: new(sync.Once)}, ctl
+}
+
+// NewClientInterceptorWithControl returns a client interceptor, as
+// NewClientInterceptor does, and the Control reconfiguring it at runtime.
+func NewClientInterceptorWithControl(opts ...ClientOption) (connect.Interceptor, *Control) {
+	ctl := newControl(clientDefaults, anyOptions(opts))
+	return &clientInterceptor{cfg: ctl.cfg, startup: new(sync.Once)}, ctl
+}
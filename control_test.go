@@ -0,0 +1,131 @@
+package connect
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestControl(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor, ctl := NewServerInterceptorWithControl(WithService("svc"))
+	call := interceptor.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+	do := func() {
+		t.Helper()
+		if _, err := call(context.Background(), connect.NewRequest(&wrapperspb.StringValue{Value: "hello"})); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	do()
+	ctl.Update(WithRequestTags())
+	do()
+	ctl.Update(WithUntracedMethods("*"))
+	do()
+	ctl.Reset()
+	do()
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	if got := spans[0].Tag(tagRequest); got != nil {
+		t.Errorf("expected no request tag before the update, got %v", got)
+	}
+	if got, _ := spans[1].Tag(tagRequest).(string); !strings.Contains(got, "hello") {
+		t.Errorf("expected request tag after the update, got %q", got)
+	}
+	if got := spans[1].Tag(ext.ServiceName); got != "svc" {
+		t.Errorf("expected updates to keep the previous options, got service %v", got)
+	}
+	if got := spans[2].Tag(tagRequest); got != nil {
+		t.Errorf("expected Reset to restore the defaults, got %v", got)
+	}
+	if got := spans[2].Tag(ext.ServiceName); got != defaultServerServiceName {
+		t.Errorf("expected Reset to restore the default service, got %v", got)
+	}
+}
+
+func TestControlSetters(t *testing.T) {
+	_, ctl := NewServerInterceptorWithControl(
+		WithRequestTags(),
+		WithProcedureOptions("/a.S/M", WithStreamMessages(false)),
+	)
+	ctl.setRemote([]Option{WithAnalyticsRate(0.5)})
+
+	for range 3 {
+		ctl.SetRequestTags(false)
+		ctl.SetRequestTags(true)
+	}
+	ctl.SetMetadataTags(true)
+	ctl.SetStreamCalls(false)
+	ctl.SetEnabled(false)
+	ctl.SetEnabled(true)
+
+	cfg := ctl.cfg.Load()
+	if !cfg.enabled || !cfg.withRequestTags || !cfg.withMetadataTags || cfg.traceStreamCalls {
+		t.Errorf("expected the last setters to win, got enabled=%v request tags=%v metadata tags=%v stream calls=%v",
+			cfg.enabled, cfg.withRequestTags, cfg.withMetadataTags, cfg.traceStreamCalls)
+	}
+	if !cfg.traceStreamMessages {
+		t.Error("expected stream messages to stay traced")
+	}
+	if cfg.forProcedure(connect.Spec{Procedure: "/a.S/M"}).traceStreamMessages {
+		t.Error("expected updates to keep the procedure options")
+	}
+	if cfg.analyticsOpt == 0 {
+		t.Error("expected the remote settings to apply on top of the updates")
+	}
+
+	ctl.SetRequestTags(false)
+	if ctl.cfg.Load().withRequestTags {
+		t.Error("expected SetRequestTags(false) to disable request tags")
+	}
+	ctl.Reset()
+	if cfg := ctl.cfg.Load(); cfg.withMetadataTags || cfg.analyticsOpt == 0 {
+		t.Error("expected Reset to restore the defaults and keep the remote settings")
+	}
+}
+
+func TestControlConcurrentUpdates(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor, ctl := NewClientInterceptorWithControl()
+	call := interceptor.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				_, _ = call(context.Background(), connect.NewRequest(&wrapperspb.StringValue{}))
+			}
+		}()
+	}
+	for i := range 100 {
+		if i%2 == 0 {
+			ctl.Update(WithRequestTags(), WithCustomTag("update", i))
+		} else {
+			ctl.Reset(WithAnalyticsRate(0.5))
+		}
+	}
+	wg.Wait()
+
+	if got := len(mt.FinishedSpans()); got != 400 {
+		t.Errorf("expected 400 spans, got %d", got)
+	}
+}
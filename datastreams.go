@@ -0,0 +1,93 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/datastreams"
+	"github.com/DataDog/dd-trace-go/v2/datastreams/options"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/proto"
+)
+
+// envDataStreamsEnabled enables Data Streams Monitoring in dd-trace-go, and is
+// the default of WithDataStreams.
+const envDataStreamsEnabled = "DD_DATA_STREAMS_ENABLED"
+
+// WithDataStreams specifies whether streaming calls set Data Streams
+// Monitoring checkpoints: each message sent by a client sets an outbound
+// checkpoint and each message received by a handler sets the matching inbound
+// one, with the procedure as topic. Only the first message of a stream carries
+// the client's pathway. It defaults to DD_DATA_STREAMS_ENABLED.
+func WithDataStreams(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.dataStreams = enabled
+	}
+}
+
+// dataStreamsProcessor sets Data Streams Monitoring checkpoints and propagates
+// pathways through request headers.
+type dataStreamsProcessor interface {
+	checkpoint(ctx context.Context, payloadSize int64, edgeTags ...string) context.Context
+	inject(ctx context.Context, header http.Header)
+	extract(ctx context.Context, header http.Header) context.Context
+}
+
+// dataStreams is the processor of the tracer, replaced by a stand-in in tests.
+var dataStreams dataStreamsProcessor = tracerDataStreams{}
+
+type tracerDataStreams struct{}
+
+func (tracerDataStreams) checkpoint(ctx context.Context, payloadSize int64, edgeTags ...string) context.Context {
+	ctx, _ = tracer.SetDataStreamsCheckpointWithParams(ctx, options.CheckpointParams{PayloadSize: payloadSize}, edgeTags...)
+	return ctx
+}
+
+func (tracerDataStreams) inject(ctx context.Context, header http.Header) {
+	datastreams.InjectToBase64Carrier(ctx, tracer.HTTPHeadersCarrier(header))
+}
+
+func (tracerDataStreams) extract(ctx context.Context, header http.Header) context.Context {
+	return datastreams.ExtractFromBase64Carrier(ctx, tracer.HTTPHeadersCarrier(header))
+}
+
+// dataStreamsEdgeTags returns the edge tags of the checkpoints of messages of
+// procedure in direction, "in" or "out".
+func dataStreamsEdgeTags(direction, procedure string) []string {
+	return []string{"direction:" + direction, "topic:" + procedure, "type:" + extRPCSystemConnect}
+}
+
+// messageSize returns the size of the protobuf encoding of msg, or 0 when msg
+// is not a protobuf message.
+func messageSize(msg any) int64 {
+	if m, ok := msg.(proto.Message); ok {
+		return int64(proto.Size(m))
+	}
+	return 0
+}
+
+var _ connect.StreamingClientConn = (*dataStreamsClientConn)(nil)
+
+// dataStreamsClientConn sets an outbound checkpoint for each message sent. The
+// first one continues the pathway of the stream's context and is propagated
+// through the request headers, which are sent along with it. connect has no
+// per-message metadata, so the following messages start pathways of their own:
+// continuing the stream's pathway would report the age of the stream as their
+// latency.
+type dataStreamsClientConn struct {
+	connect.StreamingClientConn
+	ctx  context.Context
+	sent bool
+}
+
+func (c *dataStreamsClientConn) Send(m any) error {
+	edgeTags := dataStreamsEdgeTags("out", c.Spec().Procedure)
+	if c.sent {
+		dataStreams.checkpoint(context.Background(), messageSize(m), edgeTags...)
+	} else {
+		dataStreams.inject(dataStreams.checkpoint(c.ctx, messageSize(m), edgeTags...), c.RequestHeader())
+		c.sent = true
+	}
+	return c.StreamingClientConn.Send(m)
+}
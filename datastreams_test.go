@@ -0,0 +1,128 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const fakePathwayHeader = "Fake-Pathway"
+
+type fakePathwayKey struct{}
+
+// fakeDataStreams stands in for the tracer's Data Streams processor: pathways
+// are the edge tags of their checkpoints, joined by " > ".
+type fakeDataStreams struct {
+	mu          sync.Mutex
+	checkpoints []string
+}
+
+func (d *fakeDataStreams) checkpoint(ctx context.Context, payloadSize int64, edgeTags ...string) context.Context {
+	pathway := strings.Join(edgeTags, ",")
+	if parent, ok := ctx.Value(fakePathwayKey{}).(string); ok {
+		pathway = parent + " > " + pathway
+	}
+	d.mu.Lock()
+	d.checkpoints = append(d.checkpoints, pathway)
+	d.mu.Unlock()
+	return context.WithValue(ctx, fakePathwayKey{}, pathway)
+}
+
+func (d *fakeDataStreams) inject(ctx context.Context, header http.Header) {
+	if pathway, ok := ctx.Value(fakePathwayKey{}).(string); ok {
+		header.Set(fakePathwayHeader, pathway)
+	}
+}
+
+func (d *fakeDataStreams) extract(ctx context.Context, header http.Header) context.Context {
+	if pathway := header.Get(fakePathwayHeader); pathway != "" {
+		return context.WithValue(ctx, fakePathwayKey{}, pathway)
+	}
+	return ctx
+}
+
+func useFakeDataStreams(t *testing.T) *fakeDataStreams {
+	t.Helper()
+	fake := new(fakeDataStreams)
+	processor := dataStreams
+	t.Cleanup(func() { dataStreams = processor })
+	dataStreams = fake
+	return fake
+}
+
+func TestDataStreams(t *testing.T) {
+	const procedure = "/test.Service/Upload"
+	out := "direction:out,topic:" + procedure + ",type:connect"
+	in := "direction:in,topic:" + procedure + ",type:connect"
+
+	for _, tc := range []struct {
+		name string
+		opts []AnyOption
+		want []string
+	}{
+		{name: "disabled"},
+		{
+			name: "enabled",
+			opts: []AnyOption{WithDataStreams(true)},
+			// only the first message continues the propagated pathway
+			want: []string{out, out, out + " > " + in, in},
+		},
+		{
+			name: "untraced",
+			opts: []AnyOption{WithDataStreams(true), WithUntracedMethods(procedure)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := useFakeDataStreams(t)
+			interceptor := NewInterceptor(tc.opts...)
+
+			mux := http.NewServeMux()
+			mux.Handle(procedure, connect.NewClientStreamHandler(
+				procedure,
+				func(ctx context.Context, stream *connect.ClientStream[wrapperspb.StringValue]) (*connect.Response[wrapperspb.StringValue], error) {
+					for stream.Receive() {
+					}
+					return connect.NewResponse(&wrapperspb.StringValue{}), stream.Err()
+				},
+				connect.WithInterceptors(interceptor),
+			))
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](
+				srv.Client(),
+				srv.URL+procedure,
+				connect.WithInterceptors(interceptor),
+			)
+			stream := client.CallClientStream(context.Background())
+			for _, v := range []string{"a", "b"} {
+				if err := stream.Send(wrapperspb.String(v)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if _, err := stream.CloseAndReceive(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(fake.checkpoints, tc.want) {
+				t.Errorf("expected checkpoints %q, got %q", tc.want, fake.checkpoints)
+			}
+		})
+	}
+}
+
+func TestMessageSize(t *testing.T) {
+	if got := messageSize(wrapperspb.String("abc")); got != 5 {
+		t.Errorf("expected 5 bytes, got %d", got)
+	}
+	if got := messageSize("abc"); got != 0 {
+		t.Errorf("expected 0 bytes for a non-protobuf message, got %d", got)
+	}
+}
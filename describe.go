@@ -0,0 +1,173 @@
+package connect
+
+import (
+	"encoding/json"
+	"maps"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/instrumentation"
+)
+
+// envStartupLogs disables the startup log of the interceptors when false, as it
+// does for the tracer's own startup log.
+const envStartupLogs = "DD_TRACE_STARTUP_LOGS"
+
+// Describer is implemented by the interceptors returned by this package, e.g.
+// to check the options an interceptor ended up with:
+//
+//	if d, ok := interceptor.(connecttrace.Describer); ok {
+//		log.Print(d)
+//	}
+type Describer interface {
+	// Describe returns the effective configuration of the interceptor.
+	Describe() Description
+	// String returns the effective configuration as one line of JSON.
+	String() string
+}
+
+// Description is the effective configuration of an interceptor. Server is nil
+// for client interceptors and Client is nil for server interceptors.
+type Description struct {
+	Server *ConfigDescription `json:"server,omitempty"`
+	Client *ConfigDescription `json:"client,omitempty"`
+}
+
+func (d Description) String() string {
+	b, _ := json.Marshal(d)
+	return string(b)
+}
+
+// ConfigDescription is the effective configuration of one side of an
+// interceptor. UntracedMethods lists the exact methods first, then the regular
+// expressions matching the untraced glob patterns and regular expressions.
+type ConfigDescription struct {
+	Enabled            bool     `json:"enabled"`
+	Service            string   `json:"service"`
+	SpanName           string   `json:"span_name"`
+	NonErrorCodes      []string `json:"non_error_codes"`
+	StreamCalls        bool     `json:"stream_calls"`
+	StreamMessages     bool     `json:"stream_messages"`
+	UntracedMethods    []string `json:"untraced_methods"`
+	TracedMethods      []string `json:"traced_methods,omitempty"`
+	IgnoreInfraMethods bool     `json:"ignore_infra_methods"`
+	MetadataTags       bool     `json:"metadata_tags"`
+	IgnoredMetadata    []string `json:"ignored_metadata"`
+	RequestTags        bool     `json:"request_tags"`
+	RequestFieldTags   []string `json:"request_field_tags,omitempty"`
+	PanicRecovery      bool     `json:"panic_recovery,omitempty"`
+	ContextTagsBaggage bool     `json:"context_tags_baggage,omitempty"`
+	BaggageTagKeys     []string `json:"baggage_tag_keys"`
+	AppSec             bool     `json:"appsec,omitempty"`
+	APISecurity        bool     `json:"api_security,omitempty"`
+	DataStreams        bool     `json:"data_streams,omitempty"`
+	MessageContext     string   `json:"message_trace_context,omitempty"`
+	FanInLinks         int      `json:"fan_in_links,omitempty"`
+	Procedures         []string `json:"procedure_options,omitempty"`
+}
+
+// describe returns the description of cfg.
+func (cfg *config) describe() *ConfigDescription {
+	d := &ConfigDescription{
+		Enabled:            cfg.enabled,
+		SpanName:           cfg.spanName,
+		NonErrorCodes:      []string{},
+		StreamCalls:        cfg.traceStreamCalls,
+		StreamMessages:     cfg.traceStreamMessages,
+		UntracedMethods:    []string{},
+		IgnoreInfraMethods: cfg.ignoreInfraMethods,
+		MetadataTags:       cfg.withMetadataTags,
+		IgnoredMetadata:    slices.Sorted(maps.Keys(cfg.ignoredMetadata)),
+		RequestTags:        cfg.withRequestTags,
+		RequestFieldTags:   cfg.requestFieldTags,
+		PanicRecovery:      cfg.recoverPanics,
+		ContextTagsBaggage: cfg.contextTagsBaggage,
+		BaggageTagKeys:     slices.Sorted(maps.Keys(cfg.baggageTagKeys)),
+		AppSec:             cfg.appsec,
+		APISecurity:        cfg.appsec && cfg.apiSecurity,
+		DataStreams:        cfg.dataStreams,
+		MessageContext:     string(cfg.msgContextField),
+		FanInLinks:         cfg.fanInLinks,
+	}
+	if cfg.serviceName != nil {
+		d.Service = cfg.serviceName()
+	}
+	for c := range cfg.nonErrorCodes {
+		d.NonErrorCodes = append(d.NonErrorCodes, c.String())
+	}
+	slices.Sort(d.NonErrorCodes)
+	d.UntracedMethods = append(d.UntracedMethods, slices.Sorted(maps.Keys(cfg.untracedMethods))...)
+	d.UntracedMethods = append(d.UntracedMethods, slices.Sorted(maps.Keys(cfg.ignoredMethods))...)
+	for _, res := range [][]*regexp.Regexp{cfg.untracedPatterns, cfg.ignoredPatterns, cfg.untracedRegexps} {
+		for _, re := range res {
+			d.UntracedMethods = append(d.UntracedMethods, re.String())
+		}
+	}
+	if cfg.tracedMethods != nil {
+		d.TracedMethods = slices.Sorted(maps.Keys(cfg.tracedMethods))
+		for _, res := range [][]*regexp.Regexp{cfg.tracedPatterns, cfg.tracedRegexps} {
+			for _, re := range res {
+				d.TracedMethods = append(d.TracedMethods, re.String())
+			}
+		}
+	}
+	for _, po := range cfg.procedureOpts {
+		d.Procedures = append(d.Procedures, po.procedure)
+	}
+	return d
+}
+
+func (s serverInterceptor) Describe() Description {
+	return Description{Server: s.cfg.Load().describe()}
+}
+
+func (s serverInterceptor) String() string { return s.Describe().String() }
+
+func (c clientInterceptor) Describe() Description {
+	return Description{Client: c.cfg.Load().describe()}
+}
+
+func (c clientInterceptor) String() string { return c.Describe().String() }
+
+func (i interceptor) Describe() Description {
+	return Description{
+		Server: i.server.cfg.Load().describe(),
+		Client: i.client.cfg.Load().describe(),
+	}
+}
+
+func (i interceptor) String() string { return i.Describe().String() }
+
+// startupLog is the startup log line of an interceptor.
+type startupLog struct {
+	Date        string `json:"date"`
+	Integration string `json:"integration"`
+	Version     string `json:"version"`
+	Description
+}
+
+// logStartup logs the configuration of d once, unless DD_TRACE_STARTUP_LOGS is
+// false. once is nil for the interceptors wrapped by NewInterceptor, which logs
+// both sides in one line.
+func logStartup(once *sync.Once, d Describer) {
+	if once == nil {
+		return
+	}
+	once.Do(func() {
+		if !boolEnv(envStartupLogs, true) {
+			return
+		}
+		b, err := json.Marshal(startupLog{
+			Date:        time.Now().Format(time.RFC3339),
+			Integration: componentName,
+			Version:     instrumentation.Version(),
+			Description: d.Describe(),
+		})
+		if err != nil {
+			return
+		}
+		logInfo("DATADOG CONNECT INTERCEPTOR CONFIGURATION %s", b)
+	})
+}
@@ -0,0 +1,89 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+)
+
+func TestDescribe(t *testing.T) {
+	interceptor := NewServerInterceptor(
+		WithService("svc"),
+		WithUntracedMethods("/test.Service/Ping", "/test.Service/*"),
+		WithIgnoredMetadata("authorization"),
+		NonErrorCodes(connect.CodeNotFound, connect.CodeCanceled),
+	)
+	d := interceptor.(Describer).Describe()
+	if d.Client != nil || d.Server == nil {
+		t.Fatalf("expected a server description only, got %+v", d)
+	}
+	s := d.Server
+	if s.Service != "svc" || s.SpanName != serverSpanName {
+		t.Errorf("unexpected service %q and span name %q", s.Service, s.SpanName)
+	}
+	if want := []string{"canceled", "not_found"}; !slices.Equal(s.NonErrorCodes, want) {
+		t.Errorf("expected non-error codes %v, got %v", want, s.NonErrorCodes)
+	}
+	if want := []string{"/test.Service/Ping", `^/test\.Service/.*$`}; !slices.Equal(s.UntracedMethods, want) {
+		t.Errorf("expected untraced methods %v, got %v", want, s.UntracedMethods)
+	}
+	if !slices.Contains(s.IgnoredMetadata, "authorization") || !slices.Contains(s.IgnoredMetadata, "traceparent") {
+		t.Errorf("expected the default and added ignored metadata, got %v", s.IgnoredMetadata)
+	}
+
+	str := interceptor.(Describer).String()
+	if strings.Contains(str, "\n") {
+		t.Errorf("expected a single line, got %q", str)
+	}
+	var decoded Description
+	if err := json.Unmarshal([]byte(str), &decoded); err != nil || decoded.Server.Service != "svc" {
+		t.Errorf("expected String to return the description as JSON, got %q (%v)", str, err)
+	}
+
+	d = NewInterceptor().(Describer).Describe()
+	if d.Server == nil || d.Client == nil || d.Client.SpanName != clientSpanName {
+		t.Errorf("expected both sides to be described, got %+v", d)
+	}
+}
+
+func TestStartupLog(t *testing.T) {
+	noop := func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) { return nil, nil }
+
+	t.Run("logged once", func(t *testing.T) {
+		logs := new(captureLogger)
+		useLogger(t, logs)
+
+		interceptor := NewInterceptor(WithService("svc"))
+		interceptor.WrapUnary(noop)
+		interceptor.WrapUnary(noop)
+		if len(logs.msgs) != 1 {
+			t.Fatalf("expected one startup log line, got %q", logs.msgs)
+		}
+		msg := logs.msgs[0]
+		if !strings.Contains(msg, "INFO: DATADOG CONNECT INTERCEPTOR CONFIGURATION {") {
+			t.Errorf("unexpected startup log %q", msg)
+		}
+		var entry startupLog
+		if err := json.Unmarshal([]byte(msg[strings.Index(msg, "{"):]), &entry); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", msg, err)
+		}
+		if entry.Integration != componentName || entry.Server == nil || entry.Client == nil {
+			t.Errorf("unexpected startup log entry %+v", entry)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv(envStartupLogs, "false")
+		logs := new(captureLogger)
+		useLogger(t, logs)
+
+		NewServerInterceptor().WrapUnary(noop)
+		if len(logs.msgs) != 0 {
+			t.Errorf("expected no startup log, got %q", logs.msgs)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package connect
+
+import (
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/instrumentation"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// envEndpointCollectionEnabled disables RegisterHandler when false, as for
+// dd-trace-go's HTTP integrations.
+const envEndpointCollectionEnabled = "DD_API_SECURITY_ENDPOINT_COLLECTION_ENABLED"
+
+// registerAppEndpoint reports an endpoint through instrumentation telemetry.
+var registerAppEndpoint = instr.TelemetryRegisterAppEndpoint
+
+// Content types of the Connect, gRPC and gRPC-Web protocols served by connect
+// handlers.
+var (
+	unaryContentTypes = []string{
+		"application/proto", "application/json",
+		"application/grpc", "application/grpc+proto", "application/grpc+json",
+		"application/grpc-web", "application/grpc-web+proto", "application/grpc-web+json",
+	}
+	streamContentTypes = []string{
+		"application/connect+proto", "application/connect+json",
+		"application/grpc", "application/grpc+proto", "application/grpc+json",
+		"application/grpc-web", "application/grpc-web+proto", "application/grpc-web+json",
+	}
+)
+
+// RegisterHandler reports every procedure of svc, served by handler at path,
+// to the Datadog API catalog through instrumentation telemetry, so procedures
+// show up before they receive traffic. It returns path and handler unchanged
+// to wrap the generated New*Handler constructors:
+//
+//	path, handler := examplev1connect.NewExampleServiceHandler(srv, opts...)
+//	mux.Handle(connecttrace.RegisterHandler(path, handler,
+//		examplev1.File_example_v1_example_proto.Services().ByName("ExampleService")))
+//
+// Endpoints are named after the server spans and their default resource name,
+// the procedure. Setting DD_API_SECURITY_ENDPOINT_COLLECTION_ENABLED to false
+// disables the reports.
+func RegisterHandler(path string, handler http.Handler, svc protoreflect.ServiceDescriptor) (string, http.Handler) {
+	if !boolEnv(envEndpointCollectionEnabled, true) {
+		return path, handler
+	}
+	methods := svc.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		procedure := path + string(md.Name())
+		registerAppEndpoint(serverSpanName, procedure, endpointAttributes(procedure, md))
+	}
+	return path, handler
+}
+
+// endpointAttributes returns the attributes of the endpoint serving md at
+// procedure.
+func endpointAttributes(procedure string, md protoreflect.MethodDescriptor) instrumentation.AppEndpointAttributes {
+	streamType := connect.StreamTypeUnary
+	if md.IsStreamingClient() {
+		streamType |= connect.StreamTypeClient
+	}
+	if md.IsStreamingServer() {
+		streamType |= connect.StreamTypeServer
+	}
+	idempotency := connect.IdempotencyUnknown
+	if opts, ok := md.Options().(*descriptorpb.MethodOptions); ok {
+		idempotency = connect.IdempotencyLevel(opts.GetIdempotencyLevel())
+	}
+
+	attrs := instrumentation.AppEndpointAttributes{
+		Kind:   "REST",
+		Method: http.MethodPost,
+		Path:   procedure,
+		Metadata: map[string]any{
+			tagMethodKind:   methodKind(streamType),
+			tagIdempotency:  idempotencyName(idempotency),
+			tagRequestType:  string(md.Input().FullName()),
+			tagResponseType: string(md.Output().FullName()),
+		},
+	}
+	if streamType == connect.StreamTypeUnary {
+		attrs.RequestBodyType = unaryContentTypes
+		attrs.ResponseBodyType = unaryContentTypes
+		// handlers also serve procedures without side effects to GET requests
+		if idempotency == connect.IdempotencyNoSideEffects {
+			attrs.Method = "*"
+		}
+	} else {
+		attrs.RequestBodyType = streamContentTypes
+		attrs.ResponseBodyType = streamContentTypes
+	}
+	return attrs
+}
@@ -0,0 +1,112 @@
+package connect
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/DataDog/dd-trace-go/v2/instrumentation"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// testServiceDescriptor returns the descriptor of a test.v1.TestService with
+// a method of each stream type.
+func testServiceDescriptor(t *testing.T) protoreflect.ServiceDescriptor {
+	t.Helper()
+	method := func(name string, client, server bool, idempotency descriptorpb.MethodOptions_IdempotencyLevel) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".test.v1.Message"),
+			OutputType:      proto.String(".test.v1.Message"),
+			ClientStreaming: proto.Bool(client),
+			ServerStreaming: proto.Bool(server),
+			Options:         &descriptorpb.MethodOptions{IdempotencyLevel: idempotency.Enum()},
+		}
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("test/v1/test.proto"),
+		Package:     proto.String("test.v1"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Message")}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("TestService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("Get", false, false, descriptorpb.MethodOptions_NO_SIDE_EFFECTS),
+				method("Put", false, false, descriptorpb.MethodOptions_IDEMPOTENT),
+				method("Watch", false, true, descriptorpb.MethodOptions_IDEMPOTENCY_UNKNOWN),
+				method("Chat", true, true, descriptorpb.MethodOptions_IDEMPOTENCY_UNKNOWN),
+			},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Services().Get(0)
+}
+
+type registeredEndpoint struct {
+	opName, resName string
+	attrs           instrumentation.AppEndpointAttributes
+}
+
+func captureEndpoints(t *testing.T) *[]registeredEndpoint {
+	t.Helper()
+	var endpoints []registeredEndpoint
+	register := registerAppEndpoint
+	t.Cleanup(func() { registerAppEndpoint = register })
+	registerAppEndpoint = func(opName, resName string, attrs instrumentation.AppEndpointAttributes) {
+		endpoints = append(endpoints, registeredEndpoint{opName, resName, attrs})
+	}
+	return &endpoints
+}
+
+func TestRegisterHandler(t *testing.T) {
+	endpoints := captureEndpoints(t)
+	handler := http.NotFoundHandler()
+	path, got := RegisterHandler("/test.v1.TestService/", handler, testServiceDescriptor(t))
+	if path != "/test.v1.TestService/" || reflect.ValueOf(got).Pointer() != reflect.ValueOf(handler).Pointer() {
+		t.Fatalf("expected the path and handler to be returned unchanged")
+	}
+
+	want := []struct {
+		procedure, method, kind, idempotency string
+	}{
+		{"/test.v1.TestService/Get", "*", methodKindUnary, idempotencyNoSideEffects},
+		{"/test.v1.TestService/Put", http.MethodPost, methodKindUnary, idempotencyIdempotent},
+		{"/test.v1.TestService/Watch", http.MethodPost, methodKindServerStream, idempotencyUnknown},
+		{"/test.v1.TestService/Chat", http.MethodPost, methodKindBidiStream, idempotencyUnknown},
+	}
+	if len(*endpoints) != len(want) {
+		t.Fatalf("expected %d endpoints, got %d", len(want), len(*endpoints))
+	}
+	for i, w := range want {
+		e := (*endpoints)[i]
+		if e.opName != serverSpanName || e.resName != w.procedure || e.attrs.Path != w.procedure {
+			t.Errorf("expected endpoint %s of %s, got %+v", w.procedure, serverSpanName, e)
+		}
+		if e.attrs.Method != w.method {
+			t.Errorf("%s: expected method %s, got %s", w.procedure, w.method, e.attrs.Method)
+		}
+		if got := e.attrs.Metadata[tagMethodKind]; got != w.kind {
+			t.Errorf("%s: expected kind %s, got %v", w.procedure, w.kind, got)
+		}
+		if got := e.attrs.Metadata[tagIdempotency]; got != w.idempotency {
+			t.Errorf("%s: expected idempotency %s, got %v", w.procedure, w.idempotency, got)
+		}
+		if got := e.attrs.Metadata[tagRequestType]; got != "test.v1.Message" {
+			t.Errorf("%s: expected request type test.v1.Message, got %v", w.procedure, got)
+		}
+	}
+}
+
+func TestRegisterHandlerDisabled(t *testing.T) {
+	t.Setenv(envEndpointCollectionEnabled, "false")
+	endpoints := captureEndpoints(t)
+	RegisterHandler("/test.v1.TestService/", http.NotFoundHandler(), testServiceDescriptor(t))
+	if len(*endpoints) != 0 {
+		t.Errorf("expected no endpoints, got %d", len(*endpoints))
+	}
+}
@@ -0,0 +1,78 @@
+package connect
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by defaults. Options given to the interceptor
+// constructors take precedence over them.
+const (
+	// envEnabled is the default of WithEnabled.
+	envEnabled = "DD_TRACE_CONNECT_ENABLED"
+	// envStreamCalls is the default of WithStreamCalls.
+	envStreamCalls = "DD_TRACE_CONNECT_STREAM_CALLS"
+	// envStreamMessages is the default of WithStreamMessages.
+	envStreamMessages = "DD_TRACE_CONNECT_STREAM_MESSAGES"
+	// envUntracedMethods is a comma-separated list of methods, as given to
+	// WithUntracedMethods.
+	envUntracedMethods = "DD_TRACE_CONNECT_UNTRACED_METHODS"
+	// envMetadataTags enables WithMetadataTags when true.
+	envMetadataTags = "DD_TRACE_CONNECT_METADATA_TAGS"
+	// envIgnoredMetadata is a comma-separated list of metadata keys, as given
+	// to WithIgnoredMetadata.
+	envIgnoredMetadata = "DD_TRACE_CONNECT_IGNORED_METADATA"
+	// envRequestTags enables WithRequestTags when true.
+	envRequestTags = "DD_TRACE_CONNECT_REQUEST_TAGS"
+	// envAnalyticsEnabled enables WithAnalytics when true.
+	envAnalyticsEnabled = "DD_TRACE_CONNECT_ANALYTICS_ENABLED"
+)
+
+// boolEnv returns the boolean value of the environment variable key, or def
+// when it is unset or invalid.
+func boolEnv(key string, def bool) bool {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// listEnv returns the non-empty, comma-separated values of the environment
+// variable key.
+func listEnv(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// envDefaults applies the DD_TRACE_CONNECT_* environment variables to cfg.
+func envDefaults(cfg *config) {
+	cfg.enabled = boolEnv(envEnabled, cfg.enabled)
+	cfg.traceStreamCalls = boolEnv(envStreamCalls, cfg.traceStreamCalls)
+	cfg.traceStreamMessages = boolEnv(envStreamMessages, cfg.traceStreamMessages)
+	cfg.withMetadataTags = boolEnv(envMetadataTags, cfg.withMetadataTags)
+	cfg.withRequestTags = boolEnv(envRequestTags, cfg.withRequestTags)
+	if ms := listEnv(envUntracedMethods); len(ms) > 0 {
+		WithUntracedMethods(ms...)(cfg)
+	}
+	if ms := listEnv(envIgnoredMetadata); len(ms) > 0 {
+		// header names are matched lowercased
+		for i, m := range ms {
+			ms[i] = strings.ToLower(m)
+		}
+		WithIgnoredMetadata(ms...)(cfg)
+	}
+	WithAnalytics(boolEnv(envAnalyticsEnabled, false))(cfg)
+	setBaggageTagKeys(cfg, baggageTagKeysFromEnv())
+	cfg.dataStreams = boolEnv(envDataStreamsEnabled, false)
+}
@@ -0,0 +1,113 @@
+package connect
+
+import (
+	"testing"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+)
+
+func TestEnvDefaults(t *testing.T) {
+	t.Setenv(envStreamCalls, "false")
+	t.Setenv(envStreamMessages, "0")
+	t.Setenv(envUntracedMethods, "/test.Service/Method, /other.Service/* ,")
+	t.Setenv(envMetadataTags, "true")
+	t.Setenv(envIgnoredMetadata, "x-secret")
+	t.Setenv(envRequestTags, "true")
+	t.Setenv(envAnalyticsEnabled, "true")
+
+	cfg := new(config)
+	serverDefaults(cfg)
+
+	if cfg.traceStreamCalls {
+		t.Error("expected traceStreamCalls to be disabled by the environment")
+	}
+	if cfg.traceStreamMessages {
+		t.Error("expected traceStreamMessages to be disabled by the environment")
+	}
+	if !cfg.withMetadataTags {
+		t.Error("expected withMetadataTags to be enabled by the environment")
+	}
+	if _, ok := cfg.ignoredMetadata["x-secret"]; !ok {
+		t.Error("expected x-secret to be in ignoredMetadata")
+	}
+	if !cfg.withRequestTags {
+		t.Error("expected withRequestTags to be enabled by the environment")
+	}
+	if len(cfg.spanOpts) != 1 {
+		t.Errorf("expected the analytics span option, got %d span options", len(cfg.spanOpts))
+	}
+	if cfg.isTraced("/test.Service/Method") || cfg.isTraced("/other.Service/Method") {
+		t.Error("expected the untraced methods from the environment to be skipped")
+	}
+	if !cfg.isTraced("/test.Service/Other") {
+		t.Error("expected other methods to be traced")
+	}
+}
+
+func TestEnvDefaultsPrecedence(t *testing.T) {
+	t.Setenv(envStreamCalls, "false")
+	t.Setenv(envUntracedMethods, "/test.Service/Method")
+
+	interceptor := NewServerInterceptor(
+		WithStreamCalls(true),
+		WithUntracedMethods("/other.Service/Method"),
+	).(*serverInterceptor)
+
+	if !interceptor.cfg.Load().traceStreamCalls {
+		t.Error("expected WithStreamCalls to take precedence over the environment")
+	}
+	if !interceptor.cfg.Load().isTraced("/test.Service/Method") {
+		t.Error("expected WithUntracedMethods to take precedence over the environment")
+	}
+}
+
+func TestEnvDisabled(t *testing.T) {
+	t.Setenv(envEnabled, "false")
+
+	cfg := new(config)
+	clientDefaults(cfg)
+	if cfg.isTraced("/test.Service/Method") {
+		t.Error("expected tracing to be disabled by the environment")
+	}
+}
+
+func TestEnvInvalidValues(t *testing.T) {
+	t.Setenv(envStreamCalls, "nope")
+	t.Setenv(envMetadataTags, "")
+
+	cfg := new(config)
+	serverDefaults(cfg)
+	if !cfg.traceStreamCalls {
+		t.Error("expected invalid values to keep the default")
+	}
+	if cfg.withMetadataTags {
+		t.Error("expected empty values to keep the default")
+	}
+}
+
+func TestEnvDefaultsOverridden(t *testing.T) {
+	t.Setenv(envEnabled, "false")
+	t.Setenv(envAnalyticsEnabled, "true")
+	t.Setenv(envIgnoredMetadata, " X-Secret ,X-Other")
+
+	cfg := newConfig(serverDefaults, []AnyOption{WithEnabled(true), WithAnalytics(false)})
+	if !cfg.isTraced("/test.Service/Method") {
+		t.Error("expected WithEnabled to take precedence over the environment")
+	}
+	if len(cfg.spanOpts) != 0 {
+		t.Errorf("expected WithAnalytics(false) to clear the analytics rate, got %d span options", len(cfg.spanOpts))
+	}
+	for _, key := range []string{"x-secret", "x-other"} {
+		if _, ok := cfg.ignoredMetadata[key]; !ok {
+			t.Errorf("expected %s to be in ignoredMetadata", key)
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("expected the environment keys to be normalized, got %v", err)
+	}
+
+	cfg = newConfig(serverDefaults, []AnyOption{WithSpanOptions(tracer.Tag("a", "b")), WithAnalyticsRate(0.5)})
+	if len(cfg.spanOpts) != 2 {
+		t.Errorf("expected WithAnalyticsRate to replace the analytics rate, got %d span options", len(cfg.spanOpts))
+	}
+}
@@ -0,0 +1,110 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestUnaryHooks(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	var order []string
+	start := WithStartHook(func(ctx context.Context, span *tracer.Span, req connect.AnyRequest) {
+		if s, _ := tracer.SpanFromContext(ctx); s != span {
+			t.Error("expected ctx to carry the span")
+		}
+		order = append(order, "start")
+		span.SetTag("tenant", req.Header().Get("X-Tenant"))
+	})
+	finish := WithFinishHook(func(ctx context.Context, span *tracer.Span, resp connect.AnyResponse, err error) {
+		order = append(order, "finish")
+		if resp != nil {
+			span.SetTag("response", resp.Any().(*wrapperspb.StringValue).Value)
+		}
+		if err != nil {
+			span.SetTag("failure", err.Error())
+		}
+	})
+	for _, tt := range []struct {
+		name        string
+		interceptor connect.Interceptor
+	}{
+		{"server", NewServerInterceptor(start, finish)},
+		{"client", NewClientInterceptor(start, finish)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mt.Reset()
+			order = nil
+			fail := false
+			call := tt.interceptor.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				order = append(order, "call")
+				if fail {
+					return nil, errors.New("boom")
+				}
+				return connect.NewResponse(&wrapperspb.StringValue{Value: "pong"}), nil
+			})
+			req := connect.NewRequest(&wrapperspb.StringValue{})
+			req.Header().Set("X-Tenant", "acme")
+			_, _ = call(context.Background(), req)
+			fail = true
+			_, _ = call(context.Background(), req)
+
+			if got := order; len(got) != 6 || got[0] != "start" || got[1] != "call" || got[2] != "finish" {
+				t.Errorf("unexpected hook order %v", got)
+			}
+			spans := mt.FinishedSpans()
+			if len(spans) != 2 {
+				t.Fatalf("expected 2 spans, got %d", len(spans))
+			}
+			if got := spans[0].Tag("tenant"); got != "acme" {
+				t.Errorf("expected the start hook to tag the span, got %v", got)
+			}
+			if got := spans[0].Tag("response"); got != "pong" {
+				t.Errorf("expected the finish hook to see the response, got %v", got)
+			}
+			if got := spans[1].Tag("failure"); got != "boom" {
+				t.Errorf("expected the finish hook to see the error, got %v", got)
+			}
+		})
+	}
+}
+
+func TestStreamHooks(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor := NewServerInterceptor(
+		WithStreamStartHook(func(ctx context.Context, span *tracer.Span, conn connect.StreamingHandlerConn) {
+			span.SetTag("tenant", conn.RequestHeader().Get("X-Tenant"))
+		}),
+		WithStreamFinishHook(func(ctx context.Context, span *tracer.Span, conn connect.StreamingHandlerConn, err error) {
+			span.SetTag("failure", err.Error())
+		}),
+	)
+	conn := &fakeStreamingHandlerConn{spec: connect.Spec{
+		Procedure:  "/test.Service/Stream",
+		StreamType: connect.StreamTypeServer,
+	}}
+	conn.RequestHeader().Set("X-Tenant", "acme")
+	_ = interceptor.WrapStreamingHandler(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		return errors.New("boom")
+	})(context.Background(), conn)
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if got := spans[0].Tag("tenant"); got != "acme" {
+		t.Errorf("expected the start hook to tag the span, got %v", got)
+	}
+	if got := spans[0].Tag("failure"); got != "boom" {
+		t.Errorf("expected the finish hook to see the error, got %v", got)
+	}
+}
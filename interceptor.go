@@ -0,0 +1,57 @@
+package connect
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"connectrpc.com/connect"
+)
+
+var _ connect.Interceptor = (*interceptor)(nil)
+
+// interceptor traces both sides of the calls it intercepts, telling them apart
+// with connect.Spec.IsClient.
+type interceptor struct {
+	server  serverInterceptor
+	client  clientInterceptor
+	startup *sync.Once // see logStartup
+}
+
+func (i interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	logStartup(i.startup, i)
+	server, client := i.server.WrapUnary(next), i.client.WrapUnary(next)
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return client(ctx, req)
+		}
+		return server(ctx, req)
+	}
+}
+
+func (i interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	logStartup(i.startup, i)
+	return i.client.WrapStreamingClient(next)
+}
+
+func (i interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	logStartup(i.startup, i)
+	return i.server.WrapStreamingHandler(next)
+}
+
+// NewInterceptor returns a connect.Interceptor which traces handlers as
+// NewServerInterceptor does and clients as NewClientInterceptor does, so the
+// same value can be given to connect.WithInterceptors for both. Each side uses
+// its own span kind, default service name and propagation direction.
+// Options apply to both sides, while server-only ServerOptions only apply to
+// handlers and client-only ClientOptions to clients.
+func NewInterceptor(opts ...AnyOption) connect.Interceptor {
+	server, client := new(atomic.Pointer[config]), new(atomic.Pointer[config])
+	server.Store(newConfig(func(cfg *config) { serverDefaults(cfg); cfg.bothSides = true }, opts))
+	client.Store(newConfig(func(cfg *config) { clientDefaults(cfg); cfg.bothSides = true }, opts))
+	return &interceptor{
+		server:  serverInterceptor{cfg: server},
+		client:  clientInterceptor{cfg: client},
+		startup: new(sync.Once),
+	}
+}
@@ -3,13 +3,21 @@ package connect
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"connectrpc.com/connect"
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	tracev1 "github.com/cskd8/dd-trace-go-contrib-connect/proto/dd/trace/v1"
 )
 
 func TestServerInterceptorSpanTags(t *testing.T) {
@@ -150,3 +158,398 @@ func TestClientInterceptorSpanTagsAndPropagation(t *testing.T) {
 		t.Errorf("expected connect.request tag to contain the message, got %q", reqTag)
 	}
 }
+
+func TestServerInterceptorPanicRecovery(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor := NewServerInterceptor(WithPanicRecovery(nil))
+	next := connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		panic("boom")
+	})
+
+	req := connect.NewRequest(&wrapperspb.StringValue{})
+	resp, err := interceptor.WrapUnary(next)(context.Background(), req)
+	if resp != nil {
+		t.Errorf("expected nil response, got %v", resp)
+	}
+	if got := connect.CodeOf(err); got != connect.CodeInternal {
+		t.Errorf("expected internal error, got %v", got)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if got := span.Tag(ext.ErrorType); got != errorTypePanic {
+		t.Errorf("expected error.type panic, got %v", got)
+	}
+	if got := span.Tag(ext.ErrorMsg); got != "boom" {
+		t.Errorf("expected error.message boom, got %v", got)
+	}
+	if got, _ := span.Tag(ext.ErrorStack).(string); !strings.Contains(got, "panic") {
+		t.Errorf("expected error.stack to contain the panic, got %q", got)
+	}
+	if got := span.Tag(tagCode); got != connect.CodeInternal.String() {
+		t.Errorf("expected connect.code internal, got %v", got)
+	}
+}
+
+func TestServerInterceptorPanicHandler(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor := NewServerInterceptor(WithPanicRecovery(func(p any) error {
+		return connect.NewError(connect.CodeUnavailable, fmt.Errorf("recovered: %v", p))
+	}))
+	next := connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		panic("boom")
+	})
+
+	req := connect.NewRequest(&wrapperspb.StringValue{})
+	_, err := interceptor.WrapUnary(next)(context.Background(), req)
+	if got := connect.CodeOf(err); got != connect.CodeUnavailable {
+		t.Errorf("expected unavailable error, got %v", got)
+	}
+	if got := mt.FinishedSpans()[0].Tag(tagCode); got != connect.CodeUnavailable.String() {
+		t.Errorf("expected connect.code unavailable, got %v", got)
+	}
+}
+
+func TestServerInterceptorRepanic(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor := NewServerInterceptor(WithPanicRecovery(nil), WithRepanic(true))
+	next := connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		panic("boom")
+	})
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("expected panic to be raised again, got %v", p)
+			}
+		}()
+		_, _ = interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(&wrapperspb.StringValue{}))
+	}()
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected the span to be finished before re-panicking, got %d spans", len(spans))
+	}
+	if got := spans[0].Tag(ext.ErrorType); got != errorTypePanic {
+		t.Errorf("expected error.type panic, got %v", got)
+	}
+}
+
+func TestServerInterceptorPanicRecoveryScope(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []ServerOption
+		next  connect.UnaryFunc
+		spans int
+	}{
+		{
+			name: "untraced method",
+			opts: []ServerOption{WithTracedMethods("/test.Service/Other")},
+			next: func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				panic("boom")
+			},
+		},
+		{
+			name: "start hook",
+			opts: []ServerOption{WithStartHook(func(context.Context, *tracer.Span, connect.AnyRequest) {
+				panic("boom")
+			})},
+			next: func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&wrapperspb.StringValue{}), nil
+			},
+			spans: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			var hookErr error
+			opts := append(tt.opts, WithPanicRecovery(nil), WithFinishHook(func(_ context.Context, _ *tracer.Span, _ connect.AnyResponse, err error) {
+				hookErr = err
+			}))
+			interceptor := NewServerInterceptor(opts...)
+			req := connect.NewRequest(&wrapperspb.StringValue{})
+			_, err := interceptor.WrapUnary(tt.next)(context.Background(), req)
+			if got := connect.CodeOf(err); got != connect.CodeInternal {
+				t.Errorf("expected internal error, got %v", got)
+			}
+			if got := len(mt.FinishedSpans()); got != tt.spans {
+				t.Fatalf("expected %d spans, got %d", tt.spans, got)
+			}
+			if tt.spans > 0 && hookErr != err {
+				t.Errorf("expected the finish hooks to receive %v, got %v", err, hookErr)
+			}
+		})
+	}
+}
+
+func TestServerInterceptorStreamingPanicRecovery(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor := NewServerInterceptor(WithPanicRecovery(nil))
+	handler := connect.StreamingHandlerFunc(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		panic("boom")
+	})
+
+	conn := &fakeStreamingHandlerConn{spec: connect.Spec{
+		Procedure:  "/test.Service/Stream",
+		StreamType: connect.StreamTypeBidi,
+	}}
+	err := interceptor.WrapStreamingHandler(handler)(context.Background(), conn)
+	if got := connect.CodeOf(err); got != connect.CodeInternal {
+		t.Errorf("expected internal error, got %v", got)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if got := spans[0].Tag(ext.ErrorType); got != errorTypePanic {
+		t.Errorf("expected error.type panic, got %v", got)
+	}
+}
+
+// fakeStreamingHandlerConn is a minimal connect.StreamingHandlerConn for
+// exercising the streaming interceptors without an HTTP server.
+type fakeStreamingHandlerConn struct {
+	spec           connect.Spec
+	requestHeader  http.Header
+	responseHeader http.Header
+}
+
+func (c *fakeStreamingHandlerConn) Spec() connect.Spec { return c.spec }
+func (c *fakeStreamingHandlerConn) Peer() connect.Peer { return connect.Peer{} }
+func (c *fakeStreamingHandlerConn) Receive(any) error  { return io.EOF }
+func (c *fakeStreamingHandlerConn) Send(any) error     { return nil }
+
+func (c *fakeStreamingHandlerConn) RequestHeader() http.Header {
+	if c.requestHeader == nil {
+		c.requestHeader = http.Header{}
+	}
+	return c.requestHeader
+}
+
+func (c *fakeStreamingHandlerConn) ResponseHeader() http.Header {
+	if c.responseHeader == nil {
+		c.responseHeader = http.Header{}
+	}
+	return c.responseHeader
+}
+
+func (c *fakeStreamingHandlerConn) ResponseTrailer() http.Header { return http.Header{} }
+
+func TestServerInterceptorNameFuncs(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor := NewServerInterceptor(
+		WithService("gateway"),
+		WithServiceNameFunc(func(spec connect.Spec, header http.Header) string {
+			return header.Get("X-Tenant")
+		}),
+		WithResourceNameFunc(func(spec connect.Spec, req connect.AnyRequest) string {
+			if req.HTTPMethod() == http.MethodGet {
+				return "GET " + spec.Procedure
+			}
+			return "custom"
+		}),
+	)
+	next := connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+
+	req := connect.NewRequest(&wrapperspb.StringValue{})
+	req.Header().Set("X-Tenant", "acme")
+	if _, err := interceptor.WrapUnary(next)(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req = connect.NewRequest(&wrapperspb.StringValue{})
+	if _, err := interceptor.WrapUnary(next)(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if got := spans[0].Tag(ext.ServiceName); got != "acme" {
+		t.Errorf("expected service acme, got %v", got)
+	}
+	if got := spans[1].Tag(ext.ServiceName); got != "gateway" {
+		t.Errorf("expected WithService fallback, got %v", got)
+	}
+	if got := spans[0].Tag(ext.ResourceName); got != "custom" {
+		t.Errorf("expected resource name from WithResourceNameFunc, got %v", got)
+	}
+}
+
+func TestIntegrationTagsNotOverridden(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	override := []Option{
+		WithService("svc"),
+		WithSpanOptions(
+			tracer.Tag(ext.Component, "custom"),
+			tracer.Tag(ext.SpanKind, ext.SpanKindProducer),
+			tracer.ServiceName("other"),
+			tracer.ResourceName("other"),
+		),
+		WithCustomTag(ext.Component, "custom"),
+		WithCustomTag(ext.SpanKind, ext.SpanKindProducer),
+	}
+	next := connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+	server, client := make([]ServerOption, len(override)), make([]ClientOption, len(override))
+	for i, opt := range override {
+		server[i], client[i] = opt, opt
+	}
+	if _, err := NewServerInterceptor(server...).WrapUnary(next)(context.Background(), connect.NewRequest(&wrapperspb.StringValue{})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewClientInterceptor(client...).WrapUnary(next)(context.Background(), connect.NewRequest(&wrapperspb.StringValue{})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	for i, kind := range []string{ext.SpanKindServer, ext.SpanKindClient} {
+		if got := spans[i].Tag(ext.Component); got != componentName {
+			t.Errorf("expected component %s, got %v", componentName, got)
+		}
+		if got := spans[i].Tag(ext.SpanKind); got != kind {
+			t.Errorf("expected span.kind %s, got %v", kind, got)
+		}
+		if got := spans[i].Tag(ext.ServiceName); got != "svc" {
+			t.Errorf("expected service svc, got %v", got)
+		}
+		if got := spans[i].Tag(ext.ResourceName); got == "other" {
+			t.Errorf("expected the resource name not to be overridden, got %v", got)
+		}
+	}
+}
+
+func TestServerInterceptorProcedureOptions(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor := NewServerInterceptor(
+		WithProcedureOptions("", WithRequestTags(), WithCustomTag("debug", "on")),
+	)
+	next := connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+
+	// requests built with connect.NewRequest have an empty procedure
+	req := connect.NewRequest(&wrapperspb.StringValue{Value: "hello"})
+	if _, err := interceptor.WrapUnary(next)(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	span := mt.FinishedSpans()[0]
+	if got, _ := span.Tag(tagRequest).(string); !strings.Contains(got, "hello") {
+		t.Errorf("expected connect.request tag from the procedure options, got %q", got)
+	}
+	if got := span.Tag("debug"); got != "on" {
+		t.Errorf("expected debug tag from the procedure options, got %v", got)
+	}
+}
+
+func TestSpecTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	const procedure = "/test.Service/Method"
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewUnaryHandler(
+		procedure,
+		func(ctx context.Context, req *connect.Request[wrapperspb.StringValue]) (*connect.Response[wrapperspb.StringValue], error) {
+			return connect.NewResponse(&wrapperspb.StringValue{Value: req.Msg.Value}), nil
+		},
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithInterceptors(NewServerInterceptor()),
+	))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](
+		srv.Client(),
+		srv.URL+procedure,
+		connect.WithHTTPGet(),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithInterceptors(NewClientInterceptor()),
+	)
+	if _, err := client.CallUnary(context.Background(), connect.NewRequest(&wrapperspb.StringValue{Value: "hello"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if got := span.Tag(tagIdempotency); got != idempotencyNoSideEffects {
+			t.Errorf("%s: expected connect.idempotency no_side_effects, got %v", span.OperationName(), got)
+		}
+		if got := span.Tag(tagGet); got != "true" {
+			t.Errorf("%s: expected connect.get tag, got %v", span.OperationName(), got)
+		}
+	}
+}
+
+func TestSpecTagsSchema(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	span, _ := tracer.StartSpanFromContext(context.Background(), "test")
+	withSpecTags(connect.Spec{Schema: annotatedMethod(t, &tracev1.MethodOptions{})}, span)
+	span.Finish()
+
+	finished := mt.FinishedSpans()[0]
+	if got := finished.Tag(tagRequestType); got != "google.protobuf.StringValue" {
+		t.Errorf("expected connect.request.type google.protobuf.StringValue, got %v", got)
+	}
+	if got := finished.Tag(tagResponseType); got != "google.protobuf.StringValue" {
+		t.Errorf("expected connect.response.type google.protobuf.StringValue, got %v", got)
+	}
+	if got := finished.Tag(tagIdempotency); got != idempotencyUnknown {
+		t.Errorf("expected connect.idempotency unknown, got %v", got)
+	}
+}
+
+func TestSpecTagsRequestFieldNamedType(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	cfg := new(config)
+	serverDefaults(cfg)
+	WithRequestFieldTags("type")(cfg)
+
+	span, _ := tracer.StartSpanFromContext(context.Background(), "test")
+	withSpecTags(connect.Spec{Schema: annotatedMethod(t, &tracev1.MethodOptions{})}, span)
+	withRequestFieldTags(cfg, &descriptorpb.FieldDescriptorProto{Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()}, span)
+	span.Finish()
+
+	finished := mt.FinishedSpans()[0]
+	if got := finished.Tag(tagRequestType); got != "google.protobuf.StringValue" {
+		t.Errorf("expected connect.request.type google.protobuf.StringValue, got %v", got)
+	}
+	if got := finished.Tag(tagRequestField + "type"); got != "TYPE_STRING" {
+		t.Errorf("expected connect.request.field.type TYPE_STRING, got %v", got)
+	}
+}
@@ -0,0 +1,71 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestNewInterceptor(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	// the same interceptor is used on both sides
+	interceptor := NewInterceptor(WithRequestTags())
+
+	const procedure = "/test.Service/Method"
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewUnaryHandler(
+		procedure,
+		func(ctx context.Context, req *connect.Request[wrapperspb.StringValue]) (*connect.Response[wrapperspb.StringValue], error) {
+			return connect.NewResponse(&wrapperspb.StringValue{Value: req.Msg.Value}), nil
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](
+		srv.Client(),
+		srv.URL+procedure,
+		connect.WithInterceptors(interceptor),
+	)
+	if _, err := client.CallUnary(context.Background(), connect.NewRequest(&wrapperspb.StringValue{Value: "hello"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	server, clientSpan := spans[0], spans[1]
+	if got := server.Tag(ext.SpanKind); got != ext.SpanKindServer {
+		t.Errorf("expected the first span to be the server's, got span.kind %v", got)
+	}
+	if got := clientSpan.Tag(ext.SpanKind); got != ext.SpanKindClient {
+		t.Errorf("expected the second span to be the client's, got span.kind %v", got)
+	}
+	if server.OperationName() != serverSpanName || clientSpan.OperationName() != clientSpanName {
+		t.Errorf("unexpected operation names %q and %q", server.OperationName(), clientSpan.OperationName())
+	}
+	if got := server.Tag(ext.ServiceName); got != defaultServerServiceName {
+		t.Errorf("expected service %s, got %v", defaultServerServiceName, got)
+	}
+	if got := clientSpan.Tag(ext.ServiceName); got != defaultClientServiceName {
+		t.Errorf("expected service %s, got %v", defaultClientServiceName, got)
+	}
+	if server.ParentID() != clientSpan.SpanID() || server.TraceID() != clientSpan.TraceID() {
+		t.Error("expected the server span to continue the client's trace")
+	}
+	for _, span := range spans {
+		if span.Tag(tagRequest) == nil {
+			t.Errorf("%s: expected the options to apply to both sides", span.OperationName())
+		}
+	}
+}
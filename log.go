@@ -0,0 +1,37 @@
+package connect
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/DataDog/dd-trace-go/v2/instrumentation"
+)
+
+// instr gives access to dd-trace-go's AppSec state and instrumentation
+// telemetry. dd-trace-go does not register connectrpc.com/connect, and
+// instrumentation.Load panics for packages it does not register, so the zero
+// Instrumentation is used: the methods called on it do not depend on the
+// package.
+var instr = new(instrumentation.Instrumentation)
+
+// logger is the logger of the integration, replaced in tests.
+var logger instrumentation.Logger = stdLogger{}
+
+// logPrefix prefixes the log lines of the integration.
+const logPrefix = "connecttrace"
+
+// stdLogger writes to the standard logger. It is not the tracer's logger:
+// dd-trace-go only hands its logger to the integrations it registers.
+type stdLogger struct{}
+
+func (stdLogger) logf(level, format string, args ...any) {
+	log.Print(logPrefix + " " + level + ": " + fmt.Sprintf(format, args...))
+}
+
+func (l stdLogger) Debug(msg string, args ...any) { l.logf("DEBUG", msg, args...) }
+func (l stdLogger) Info(msg string, args ...any)  { l.logf("INFO", msg, args...) }
+func (l stdLogger) Warn(msg string, args ...any)  { l.logf("WARN", msg, args...) }
+func (l stdLogger) Error(msg string, args ...any) { l.logf("ERROR", msg, args...) }
+
+func logInfo(format string, args ...any)  { logger.Info(format, args...) }
+func logError(format string, args ...any) { logger.Error(format, args...) }
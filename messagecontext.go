@@ -0,0 +1,122 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Reason attributes of the span links of streams and their messages.
+const (
+	// linkReasonStream links message spans to the span of their stream.
+	linkReasonStream = "connect.stream"
+	// linkReasonProducer links message spans to the context of their producer.
+	linkReasonProducer = "connect.producer"
+	// linkReasonUpstream links stream spans to the producers of their messages.
+	linkReasonUpstream = "connect.upstream"
+)
+
+// WithMessageTraceContext names the map<string, string> field of streamed
+// request messages carrying the trace context of each message, as injected by
+// the sender with InjectMessage. The connect.message span of each received
+// message then joins the trace of the message's producer, or starts a new trace
+// when the field is empty, instead of being a child of the stream's span, which
+// it links to. This keeps the messages of long-lived streams out of the trace
+// of the call which opened the stream. It requires WithStreamMessages.
+func WithMessageTraceContext(field string) ServerOption {
+	return serverOption("WithMessageTraceContext", func(cfg *config) {
+		if field == "" {
+			cfg.errs = append(cfg.errs, errors.New("WithMessageTraceContext: empty field name"))
+			return
+		}
+		cfg.msgContextField = protoreflect.Name(field)
+	})
+}
+
+// WithFanInLinks is for streams aggregating messages produced by many upstream
+// requests. With WithMessageTraceContext, the connect.message span of each
+// received message then stays a child of the stream's span and links to the
+// context of the message's producer, instead of joining its trace. The stream's
+// span links to the first maxLinks distinct upstream traces it receives
+// messages from. WithFanInLinks(0) disables fan-in links.
+func WithFanInLinks(maxLinks int) ServerOption {
+	return serverOption("WithFanInLinks", func(cfg *config) {
+		if maxLinks < 0 {
+			cfg.errs = append(cfg.errs, fmt.Errorf("WithFanInLinks: negative maximum %d", maxLinks))
+			return
+		}
+		cfg.fanInLinks = maxLinks
+	})
+}
+
+// InjectMessage injects the span context of the span in ctx into the
+// map<string, string> field of msg, for handlers configured with
+// WithMessageTraceContext(field). It does nothing when ctx has no span.
+func InjectMessage(ctx context.Context, msg proto.Message, field string) error {
+	span, ok := tracer.SpanFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	fd, err := traceContextField(msg.ProtoReflect().Descriptor(), protoreflect.Name(field))
+	if err != nil {
+		return err
+	}
+	carrier := make(tracer.TextMapCarrier)
+	if err := tracer.Inject(span.Context(), carrier); err != nil {
+		return err
+	}
+	m := msg.ProtoReflect().Mutable(fd).Map()
+	for k, v := range carrier {
+		m.Set(protoreflect.ValueOfString(k).MapKey(), protoreflect.ValueOfString(v))
+	}
+	return nil
+}
+
+// extractMessage returns the span context carried by the field of msg, or nil
+// when msg carries none.
+func extractMessage(msg any, field protoreflect.Name) *tracer.SpanContext {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil
+	}
+	fd, err := traceContextField(m.ProtoReflect().Descriptor(), field)
+	if err != nil || !m.ProtoReflect().Has(fd) {
+		return nil
+	}
+	carrier := make(tracer.TextMapCarrier)
+	m.ProtoReflect().Get(fd).Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		carrier[k.String()] = v.String()
+		return true
+	})
+	sctx, err := tracer.Extract(carrier)
+	if err != nil {
+		return nil
+	}
+	return sctx
+}
+
+// traceContextField returns the map<string, string> field named field of md.
+func traceContextField(md protoreflect.MessageDescriptor, field protoreflect.Name) (protoreflect.FieldDescriptor, error) {
+	fd := md.Fields().ByName(field)
+	if fd == nil {
+		return nil, fmt.Errorf("%s has no field %s", md.FullName(), field)
+	}
+	if !fd.IsMap() || fd.MapKey().Kind() != protoreflect.StringKind || fd.MapValue().Kind() != protoreflect.StringKind {
+		return nil, fmt.Errorf("%s.%s is not a map<string, string> field", md.FullName(), field)
+	}
+	return fd, nil
+}
+
+// spanLink returns a link to the span with context sctx.
+func spanLink(sctx *tracer.SpanContext, reason string) tracer.SpanLink {
+	return tracer.SpanLink{
+		TraceID:     sctx.TraceIDLower(),
+		TraceIDHigh: sctx.TraceIDUpper(),
+		SpanID:      sctx.SpanID(),
+		Attributes:  map[string]string{"reason": reason},
+	}
+}
@@ -0,0 +1,288 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// eventDescriptor returns the descriptor of a test.v1.Event message with a
+// map<string, string> trace_context field.
+func eventDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test/v1/event.proto"),
+		Package: proto.String("test.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Event"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{
+					Name:     proto.String("name"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					JsonName: proto.String("name"),
+				},
+				{
+					Name:     proto.String("trace_context"),
+					Number:   proto.Int32(2),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".test.v1.Event.TraceContextEntry"),
+					JsonName: proto.String("traceContext"),
+				},
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("TraceContextEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:   proto.String("key"),
+						Number: proto.Int32(1),
+						Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					},
+					{
+						Name:   proto.String("value"),
+						Number: proto.Int32(2),
+						Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					},
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().Get(0)
+}
+
+// messagesConn is a streaming handler connection receiving msgs.
+type messagesConn struct {
+	fakeStreamingHandlerConn
+	msgs []proto.Message
+}
+
+func (c *messagesConn) Receive(m any) error {
+	if len(c.msgs) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), c.msgs[0])
+	c.msgs = c.msgs[1:]
+	return nil
+}
+
+func TestMessageTraceContext(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	desc := eventDescriptor(t)
+	producer, producerCtx := tracer.StartSpanFromContext(context.Background(), "producer")
+	withContext := dynamicpb.NewMessage(desc)
+	if err := InjectMessage(producerCtx, withContext, "trace_context"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	producer.Finish()
+	withoutContext := dynamicpb.NewMessage(desc)
+
+	interceptor := NewServerInterceptor(WithStreamMessages(true), WithMessageTraceContext("trace_context"))
+	handler := interceptor.WrapStreamingHandler(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		for {
+			if err := conn.Receive(dynamicpb.NewMessage(desc)); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+		}
+	})
+	conn := &messagesConn{
+		fakeStreamingHandlerConn: fakeStreamingHandlerConn{spec: connect.Spec{
+			Procedure:  "/test.v1.Service/Events",
+			StreamType: connect.StreamTypeClient,
+		}},
+		msgs: []proto.Message{withContext, withoutContext},
+	}
+	if err := handler(context.Background(), conn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var stream *mocktracer.Span
+	var messages []*mocktracer.Span
+	for _, span := range mt.FinishedSpans() {
+		switch span.OperationName() {
+		case serverSpanName:
+			stream = span
+		case "connect.message":
+			messages = append(messages, span)
+		}
+	}
+	// the last message span is the failed receive of io.EOF
+	if stream == nil || len(messages) != 3 {
+		t.Fatalf("expected a stream span and 3 message spans, got %v", mt.FinishedSpans())
+	}
+	joined, started := messages[0], messages[1]
+	if joined.TraceID() != producer.Context().TraceIDLower() || joined.ParentID() != producer.Context().SpanID() {
+		t.Errorf("expected the first message to join the producer's trace")
+	}
+	if started.TraceID() == stream.TraceID() || started.ParentID() != 0 {
+		t.Errorf("expected the second message to start a new trace")
+	}
+	for _, span := range messages[:2] {
+		links := span.Links()
+		if len(links) != 1 || links[0].SpanID != stream.SpanID() || links[0].Attributes["reason"] != linkReasonStream {
+			t.Errorf("expected a link to the stream span, got %+v", links)
+		}
+	}
+}
+
+func TestInjectMessageErrors(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "producer")
+	defer span.Finish()
+	msg := dynamicpb.NewMessage(eventDescriptor(t))
+	for _, field := range []string{"missing", "name"} {
+		if err := InjectMessage(ctx, msg, field); err == nil {
+			t.Errorf("%s: expected an error", field)
+		}
+	}
+	if err := InjectMessage(context.Background(), msg, "missing"); err != nil {
+		t.Errorf("expected no error without a span, got %v", err)
+	}
+}
+
+func TestFanInLinks(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	desc := eventDescriptor(t)
+	var producers []*tracer.Span
+	var msgs []proto.Message
+	for _, name := range []string{"a", "b", "a"} {
+		msg := dynamicpb.NewMessage(desc)
+		if name == "a" && len(producers) > 0 {
+			// a second message of the first producer
+			ctx := tracer.ContextWithSpan(context.Background(), producers[0])
+			if err := InjectMessage(ctx, msg, "trace_context"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		} else {
+			producer, ctx := tracer.StartSpanFromContext(context.Background(), "producer")
+			if err := InjectMessage(ctx, msg, "trace_context"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			producers = append(producers, producer)
+		}
+		msgs = append(msgs, msg)
+	}
+	for _, producer := range producers {
+		producer.Finish()
+	}
+
+	interceptor, err := NewServerInterceptorE(WithMessageTraceContext("trace_context"), WithFanInLinks(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := interceptor.WrapStreamingHandler(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		for conn.Receive(dynamicpb.NewMessage(desc)) == nil {
+		}
+		return nil
+	})
+	conn := &messagesConn{
+		fakeStreamingHandlerConn: fakeStreamingHandlerConn{spec: connect.Spec{
+			Procedure:  "/test.v1.Service/Events",
+			StreamType: connect.StreamTypeClient,
+		}},
+		msgs: msgs,
+	}
+	if err := handler(context.Background(), conn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var stream *mocktracer.Span
+	var messages []*mocktracer.Span
+	for _, span := range mt.FinishedSpans() {
+		switch span.OperationName() {
+		case serverSpanName:
+			stream = span
+		case "connect.message":
+			messages = append(messages, span)
+		}
+	}
+	if stream == nil || len(messages) != 4 {
+		t.Fatalf("expected a stream span and 4 message spans, got %v", mt.FinishedSpans())
+	}
+	for i, span := range messages[:3] {
+		if span.ParentID() != stream.SpanID() {
+			t.Errorf("message %d: expected the stream span as parent", i)
+		}
+		want := producers[[]int{0, 1, 0}[i]]
+		links := span.Links()
+		if len(links) != 1 || links[0].SpanID != want.Context().SpanID() || links[0].Attributes["reason"] != linkReasonProducer {
+			t.Errorf("message %d: expected a link to its producer, got %+v", i, links)
+		}
+	}
+
+	links := stream.Links()
+	if len(links) != len(producers) {
+		t.Fatalf("expected %d links to the upstream traces, got %+v", len(producers), links)
+	}
+	for i, link := range links {
+		if link.TraceID != producers[i].Context().TraceIDLower() || link.Attributes["reason"] != linkReasonUpstream {
+			t.Errorf("expected link %d to the trace of producer %d, got %+v", i, i, link)
+		}
+	}
+}
+
+func TestFanInLinksBound(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	desc := eventDescriptor(t)
+	var msgs []proto.Message
+	for range 3 {
+		producer, ctx := tracer.StartSpanFromContext(context.Background(), "producer")
+		msg := dynamicpb.NewMessage(desc)
+		if err := InjectMessage(ctx, msg, "trace_context"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		producer.Finish()
+		msgs = append(msgs, msg)
+	}
+
+	interceptor := NewServerInterceptor(WithMessageTraceContext("trace_context"), WithFanInLinks(2))
+	handler := interceptor.WrapStreamingHandler(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		for conn.Receive(dynamicpb.NewMessage(desc)) == nil {
+		}
+		return nil
+	})
+	conn := &messagesConn{
+		fakeStreamingHandlerConn: fakeStreamingHandlerConn{spec: connect.Spec{
+			Procedure:  "/test.v1.Service/Events",
+			StreamType: connect.StreamTypeClient,
+		}},
+		msgs: msgs,
+	}
+	if err := handler(context.Background(), conn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, span := range mt.FinishedSpans() {
+		if span.OperationName() == serverSpanName && len(span.Links()) != 2 {
+			t.Errorf("expected the stream span to link to 2 traces, got %d", len(span.Links()))
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package connect
+
+import (
+	"regexp"
+	"strings"
+
+	"connectrpc.com/grpchealth"
+	"connectrpc.com/grpcreflect"
+)
+
+// infraServices are the services of the connectrpc.com/grpchealth and
+// connectrpc.com/grpcreflect packages. All their procedures, including the
+// streaming Health/Watch and ServerReflectionInfo, are skipped by
+// WithIgnoreInfraMethods.
+var infraServices = map[string]struct{}{
+	grpchealth.HealthV1ServiceName:        {},
+	grpcreflect.ReflectV1ServiceName:      {},
+	grpcreflect.ReflectV1AlphaServiceName: {},
+}
+
+// isInfraMethod reports whether procedure belongs to one of infraServices.
+func isInfraMethod(procedure string) bool {
+	service := strings.TrimPrefix(procedure, "/")
+	if i := strings.LastIndexByte(service, '/'); i >= 0 {
+		service = service[:i]
+	}
+	_, ok := infraServices[service]
+	return ok
+}
+
+// splitMethods splits ms into exact full methods and glob patterns compiled to
+// regular expressions. A method containing * is a glob pattern.
+func splitMethods(ms []string) (map[string]struct{}, []*regexp.Regexp) {
+	exact := make(map[string]struct{}, len(ms))
+	var patterns []*regexp.Regexp
+	for _, m := range ms {
+		if strings.Contains(m, "*") {
+			patterns = append(patterns, globRegexp(m))
+			continue
+		}
+		exact[m] = struct{}{}
+	}
+	return exact, patterns
+}
+
+// globRegexp compiles the glob pattern, in which * matches any sequence of
+// characters including '/', into an anchored regular expression.
+func globRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// matchAny reports whether procedure matches any of patterns.
+func matchAny(patterns []*regexp.Regexp, procedure string) bool {
+	for _, re := range patterns {
+		if re.MatchString(procedure) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTraced reports whether calls to procedure should be traced according to
+// WithEnabled, WithIgnoreInfraMethods, WithIgnoredMethods, WithUntracedMethods
+// and WithTracedMethods. The result is cached per procedure so the hot path
+// stays a single map lookup.
+func (cfg *config) isTraced(procedure string) bool {
+	if cfg.tracedCache == nil {
+		return cfg.matchTraced(procedure)
+	}
+	if traced, ok := cfg.tracedCache.Load(procedure); ok {
+		return traced.(bool)
+	}
+	traced := cfg.matchTraced(procedure)
+	cfg.tracedCache.Store(procedure, traced)
+	return traced
+}
+
+func (cfg *config) matchTraced(procedure string) bool {
+	if !cfg.enabled || cfg.skipTracing {
+		return false
+	}
+	if cfg.ignoreInfraMethods && isInfraMethod(procedure) {
+		return false
+	}
+	if _, ok := cfg.ignoredMethods[procedure]; ok {
+		return false
+	}
+	if _, ok := cfg.untracedMethods[procedure]; ok {
+		return false
+	}
+	if matchAny(cfg.ignoredPatterns, procedure) || matchAny(cfg.untracedPatterns, procedure) ||
+		matchAny(cfg.untracedRegexps, procedure) {
+		return false
+	}
+	if cfg.tracedMethods == nil {
+		return true
+	}
+	if _, ok := cfg.tracedMethods[procedure]; ok {
+		return true
+	}
+	return matchAny(cfg.tracedPatterns, procedure) || matchAny(cfg.tracedRegexps, procedure)
+}
@@ -0,0 +1,145 @@
+package connect
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestIsTraced(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		procedure string
+		expected  bool
+	}{
+		{
+			name:      "default config",
+			procedure: "/test.Service/Method",
+			expected:  true,
+		},
+		{
+			name:      "health check ignored by default",
+			procedure: "/grpc.health.v1.Health/Check",
+			expected:  false,
+		},
+		{
+			name:      "health watch ignored by default",
+			procedure: "/grpc.health.v1.Health/Watch",
+			expected:  false,
+		},
+		{
+			name:      "reflection ignored by default",
+			procedure: "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+			expected:  false,
+		},
+		{
+			name:      "alpha reflection ignored by default",
+			procedure: "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+			expected:  false,
+		},
+		{
+			name:      "infra methods traced when opted out",
+			opts:      []Option{WithIgnoreInfraMethods(false)},
+			procedure: "/grpc.health.v1.Health/Check",
+			expected:  true,
+		},
+		{
+			name:      "exact untraced method",
+			opts:      []Option{WithUntracedMethods("/test.Service/Method")},
+			procedure: "/test.Service/Method",
+			expected:  false,
+		},
+		{
+			name:      "service wildcard",
+			opts:      []Option{WithUntracedMethods("/grpc.health.v1.Health/*")},
+			procedure: "/grpc.health.v1.Health/Check",
+			expected:  false,
+		},
+		{
+			name:      "package prefix wildcard",
+			opts:      []Option{WithUntracedMethods("/grpc.reflection.*")},
+			procedure: "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+			expected:  false,
+		},
+		{
+			name:      "method suffix wildcard",
+			opts:      []Option{WithUntracedMethods("*/Ping")},
+			procedure: "/test.Service/Ping",
+			expected:  false,
+		},
+		{
+			name:      "wildcard does not match other methods",
+			opts:      []Option{WithUntracedMethods("*/Ping")},
+			procedure: "/test.Service/PingPong",
+			expected:  true,
+		},
+		{
+			name:      "pattern metacharacters are literal",
+			opts:      []Option{WithUntracedMethods("/test.Service/*")},
+			procedure: "/testXService/Method",
+			expected:  true,
+		},
+		{
+			name:      "ignored method wildcard",
+			opts:      []Option{WithIgnoredMethods("/test.Service/*")},
+			procedure: "/test.Service/Method",
+			expected:  false,
+		},
+		{
+			name:      "untraced regexp",
+			opts:      []Option{WithUntracedMethodsRegexp(regexp.MustCompile(`/(Get|List)\w+$`))},
+			procedure: "/test.Service/ListItems",
+			expected:  false,
+		},
+		{
+			name:      "traced methods allowlist match",
+			opts:      []Option{WithTracedMethods("/test.Service/*")},
+			procedure: "/test.Service/Method",
+			expected:  true,
+		},
+		{
+			name:      "traced methods allowlist miss",
+			opts:      []Option{WithTracedMethods("/test.Service/*")},
+			procedure: "/other.Service/Method",
+			expected:  false,
+		},
+		{
+			name:      "traced regexp allowlist miss",
+			opts:      []Option{WithTracedMethodsRegexp(regexp.MustCompile(`^/test\.`))},
+			procedure: "/other.Service/Method",
+			expected:  false,
+		},
+		{
+			name:      "empty traced methods",
+			opts:      []Option{WithTracedMethods(), WithTracedMethodsRegexp()},
+			procedure: "/test.Service/Method",
+			expected:  true,
+		},
+		{
+			name: "untraced takes precedence over traced",
+			opts: []Option{
+				WithTracedMethods("/test.Service/*"),
+				WithUntracedMethods("/test.Service/Method"),
+			},
+			procedure: "/test.Service/Method",
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := new(config)
+			serverDefaults(cfg)
+			for _, opt := range tt.opts {
+				opt(cfg)
+			}
+
+			// check twice to exercise the cached result
+			for range 2 {
+				if got := cfg.isTraced(tt.procedure); got != tt.expected {
+					t.Errorf("expected isTraced(%q) to be %v, got %v", tt.procedure, tt.expected, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package connect
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	envService                       = "DD_SERVICE"
+	envSpanAttributeSchema           = "DD_TRACE_SPAN_ATTRIBUTE_SCHEMA"
+	envRemoveIntegrationServiceNames = "DD_TRACE_REMOVE_INTEGRATION_SERVICE_NAMES_ENABLED"
+)
+
+// namingSchema is the span attribute schema version selected with
+// DD_TRACE_SPAN_ATTRIBUTE_SCHEMA, mirroring dd-trace-go's naming schema
+// support for its own integrations.
+type namingSchema int
+
+const (
+	namingSchemaV0 namingSchema = iota
+	namingSchemaV1
+)
+
+// namingSchemaFromEnv returns the naming schema version configured in the
+// environment. Unknown values fall back to v0, as the tracer does.
+func namingSchemaFromEnv() namingSchema {
+	switch strings.ToLower(os.Getenv(envSpanAttributeSchema)) {
+	case "v1":
+		return namingSchemaV1
+	default:
+		return namingSchemaV0
+	}
+}
+
+// removeIntegrationServiceNames reports whether v0 users opted out of the
+// integration service names (connect.server, connect.client) with
+// DD_TRACE_REMOVE_INTEGRATION_SERVICE_NAMES_ENABLED.
+func removeIntegrationServiceNames() bool {
+	v, err := strconv.ParseBool(os.Getenv(envRemoveIntegrationServiceNames))
+	return err == nil && v
+}
+
+// defaultServiceName returns the service name for spans when WithService is not
+// used. In schema v0 it is the integration service name. In schema v1, or when
+// integration service names are removed, it is DD_SERVICE; when that is unset
+// too it is empty so spans inherit the tracer's service (tracer.WithService).
+func defaultServiceName(integrationName string) string {
+	if namingSchemaFromEnv() == namingSchemaV1 || removeIntegrationServiceNames() {
+		return os.Getenv(envService)
+	}
+	return integrationName
+}
@@ -0,0 +1,123 @@
+package connect
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestDefaultServiceName(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		remove   string
+		service  string
+		expected string
+	}{
+		{
+			name:     "schema v0",
+			expected: defaultServerServiceName,
+		},
+		{
+			name:     "schema v1",
+			schema:   "v1",
+			expected: "",
+		},
+		{
+			name:     "schema v1 with DD_SERVICE",
+			schema:   "v1",
+			service:  "my-service",
+			expected: "my-service",
+		},
+		{
+			name:     "schema v0 with integration service names removed",
+			schema:   "v0",
+			remove:   "true",
+			expected: "",
+		},
+		{
+			name:     "invalid schema",
+			schema:   "v2",
+			expected: defaultServerServiceName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envSpanAttributeSchema, tt.schema)
+			t.Setenv(envRemoveIntegrationServiceNames, tt.remove)
+			t.Setenv(envService, tt.service)
+
+			if got := defaultServiceName(defaultServerServiceName); got != tt.expected {
+				t.Errorf("expected service name %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestServerInterceptorNamingSchemaV1(t *testing.T) {
+	t.Setenv(envService, "my-service")
+	t.Setenv(envSpanAttributeSchema, "v1")
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	next := connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+	req := connect.NewRequest(&wrapperspb.StringValue{})
+	if _, err := NewServerInterceptor().WrapUnary(next)(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewServerInterceptor(WithService("other")).WrapUnary(next)(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if got := spans[0].Tag(ext.ServiceName); got != "my-service" {
+		t.Errorf("expected service my-service, got %v", got)
+	}
+	if got := spans[0].OperationName(); got != serverSpanName {
+		t.Errorf("expected operation name %s, got %v", serverSpanName, got)
+	}
+	if got := spans[1].Tag(ext.ServiceName); got != "other" {
+		t.Errorf("expected service other, got %v", got)
+	}
+}
+
+func TestServiceOverrideBaseService(t *testing.T) {
+	if err := tracer.Start(tracer.WithService("my-service"), tracer.WithLogStartup(false)); err != nil {
+		t.Fatalf("Failed to start tracer: %v", err)
+	}
+
+	next := connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+	var spans []*tracer.Span
+	for _, service := range []string{"my-service", "other"} {
+		interceptor := NewServerInterceptor(
+			WithService(service),
+			WithStartHook(func(_ context.Context, span *tracer.Span, _ connect.AnyRequest) { spans = append(spans, span) }),
+		)
+		if _, err := interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(&wrapperspb.StringValue{})); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// the tracer sets _dd.base_service when the spans are finished; stopping it
+	// waits for it to be done with them
+	tracer.Stop()
+
+	if got, ok := spans[0].AsMap()["_dd.base_service"]; ok {
+		t.Errorf("expected no _dd.base_service without an override, got %v", got)
+	}
+	if got := spans[1].AsMap()["_dd.base_service"]; got != "my-service" {
+		t.Errorf("expected _dd.base_service my-service, got %v", got)
+	}
+}
@@ -1,8 +1,18 @@
 package connect
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
 	"connectrpc.com/connect"
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 const (
@@ -10,39 +20,165 @@ const (
 	defaultClientServiceName = "connect.client"
 )
 
-// Option specifies a configuration option for the grpc package. Not all options apply
-// to all instrumented structures.
+// Option is a configuration option applying to both server and client
+// interceptors. It is a ServerOption and a ClientOption, so it can be given to
+// every constructor.
 type Option func(*config)
 
+// CommonOption is an Option, which applies to both server and client
+// interceptors.
+type CommonOption = Option
+
+// AnyOption is an Option, a ServerOption or a ClientOption, as taken by
+// NewInterceptor, WithProcedureOptions and Control.
+type AnyOption interface {
+	isOption()
+}
+
+// ServerOption is an option for NewServerInterceptor. Options which only apply
+// to server interceptors, such as WithPanicRecovery, are ServerOptions but not
+// ClientOptions, so giving them to NewClientInterceptor fails to compile.
+type ServerOption interface {
+	AnyOption
+	applyServer(*config)
+}
+
+// ClientOption is an option for NewClientInterceptor. Options which only apply
+// to client interceptors, such as WithContextTagsBaggage, are ClientOptions but
+// not ServerOptions.
+type ClientOption interface {
+	AnyOption
+	applyClient(*config)
+}
+
+func (Option) isOption()                 {}
+func (o Option) applyServer(cfg *config) { o(cfg) }
+func (o Option) applyClient(cfg *config) { o(cfg) }
+
+// serverOnlyOption is a ServerOption which does not apply to clients. name is
+// the option reported when it is given to a client interceptor at runtime,
+// through NewInterceptor, WithProcedureOptions or Control.
+type serverOnlyOption struct {
+	name string
+	fn   func(*config)
+}
+
+func (serverOnlyOption) isOption()                 {}
+func (o serverOnlyOption) applyServer(cfg *config) { o.fn(cfg) }
+
+// clientOnlyOption is a ClientOption which does not apply to servers, as
+// serverOnlyOption is for clients.
+type clientOnlyOption struct {
+	name string
+	fn   func(*config)
+}
+
+func (clientOnlyOption) isOption()                 {}
+func (o clientOnlyOption) applyClient(cfg *config) { o.fn(cfg) }
+
+// serverOption returns a ServerOption applying fn to server interceptors only.
+func serverOption(name string, fn func(*config)) ServerOption {
+	return serverOnlyOption{name: name, fn: fn}
+}
+
+// clientOption returns a ClientOption applying fn to client interceptors only.
+func clientOption(name string, fn func(*config)) ClientOption {
+	return clientOnlyOption{name: name, fn: fn}
+}
+
+// apply applies opt to cfg when it applies to the side of cfg. Otherwise it
+// records an error, unless cfg is one side of NewInterceptor, which applies
+// each option to the sides it supports.
+func (cfg *config) apply(opt AnyOption) {
+	if cfg.isClient {
+		if o, ok := opt.(ClientOption); ok {
+			o.applyClient(cfg)
+		} else if o, ok := opt.(serverOnlyOption); ok && !cfg.bothSides {
+			cfg.errs = append(cfg.errs, fmt.Errorf("%s only applies to server interceptors", o.name))
+		}
+		return
+	}
+	if o, ok := opt.(ServerOption); ok {
+		o.applyServer(cfg)
+	} else if o, ok := opt.(clientOnlyOption); ok && !cfg.bothSides {
+		cfg.errs = append(cfg.errs, fmt.Errorf("%s only applies to client interceptors", o.name))
+	}
+}
+
+// anyOptions returns opts as AnyOptions.
+func anyOptions[O AnyOption](opts []O) []AnyOption {
+	res := make([]AnyOption, len(opts))
+	for i, opt := range opts {
+		res[i] = opt
+	}
+	return res
+}
+
 type config struct {
+	isClient            bool
+	bothSides           bool // see NewInterceptor
+	enabled             bool
 	serviceName         func() string
+	serviceNameFunc     func(connect.Spec, http.Header) string
+	resourceNameFunc    func(connect.Spec, connect.AnyRequest) string
 	spanName            string
 	nonErrorCodes       map[connect.Code]bool
 	traceStreamCalls    bool
 	traceStreamMessages bool
 	noDebugStack        bool
 	ignoredMethods      map[string]struct{}
+	ignoredPatterns     []*regexp.Regexp
 	untracedMethods     map[string]struct{}
+	untracedPatterns    []*regexp.Regexp
+	untracedRegexps     []*regexp.Regexp
+	tracedMethods       map[string]struct{}
+	tracedPatterns      []*regexp.Regexp
+	tracedRegexps       []*regexp.Regexp
+	tracedCache         *sync.Map // procedure -> bool, see isTraced
+	ignoreInfraMethods  bool
 	withMetadataTags    bool
 	ignoredMetadata     map[string]struct{}
 	withRequestTags     bool
+	requestFieldTags    []string
+	skipTracing         bool
 	spanOpts            []tracer.StartSpanOption
+	analyticsOpt        int // position of the analytics rate in spanOpts, from 1
 	tags                map[string]interface{}
+	recoverPanics       bool
+	panicHandler        func(any) error
+	repanic             bool
+	startHooks          []func(context.Context, *tracer.Span, connect.AnyRequest)
+	finishHooks         []func(context.Context, *tracer.Span, connect.AnyResponse, error)
+	streamStartHooks    []func(context.Context, *tracer.Span, connect.StreamingHandlerConn)
+	streamFinishHooks   []func(context.Context, *tracer.Span, connect.StreamingHandlerConn, error)
+	contextTagsBaggage  bool
+	baggageTagKeys      map[string]struct{}
+	allBaggageTags      bool
+	userExtractor       func(connect.AnyRequest) string
+	appsec              bool
+	appsecBlockingCode  connect.Code
+	apiSecurity         bool
+	dataStreams         bool
+	msgContextField     protoreflect.Name // see WithMessageTraceContext
+	fanInLinks          int
+	procedureOpts       []procedureOptions
+	procedureCache      *sync.Map // procedure -> *config, see forProcedure
+	errs                []error   // invalid options, see validate
 }
 
-// InterceptorOption represents an option that can be passed to the grpc unary
-// client and server interceptors.
+// InterceptorOption represents an option that can be passed to both the client
+// and server interceptors.
 // InterceptorOption is deprecated in favor of Option.
 type InterceptorOption = Option
 
 func defaults(cfg *config) {
+	cfg.enabled = true
 	cfg.traceStreamCalls = true
 	cfg.traceStreamMessages = true
+	cfg.ignoreInfraMethods = true
+	cfg.tracedCache = new(sync.Map)
+	cfg.procedureCache = new(sync.Map)
 	cfg.nonErrorCodes = map[connect.Code]bool{connect.CodeCanceled: true}
-	// cfg.spanOpts = append(cfg.spanOpts, tracer.AnalyticsRate(globalconfig.AnalyticsRate()))
-	//if internal.BoolEnv("DD_TRACE_GRPC_ANALYTICS_ENABLED", false) {
-	//	cfg.spanOpts = append(cfg.spanOpts, tracer.AnalyticsRate(1.0))
-	//}
 	cfg.ignoredMetadata = map[string]struct{}{
 		"x-datadog-trace-id":          {},
 		"x-datadog-parent-id":         {},
@@ -51,39 +187,72 @@ func defaults(cfg *config) {
 		"traceparent":                 {},
 		"tracestate":                  {},
 	}
+	envDefaults(cfg)
 }
 
+// Operation names already follow the v1 naming schema conventions, so they are
+// the same under every DD_TRACE_SPAN_ATTRIBUTE_SCHEMA version.
+const (
+	serverSpanName = "connect.server.request"
+	clientSpanName = "connect.client.request"
+)
+
 func serverDefaults(cfg *config) {
 	// We check for a configured service name, so we don't break users who are incorrectly creating their server
 	// before the call `tracer.Start()`
-	cfg.serviceName = func() string { return defaultServerServiceName }
-	cfg.spanName = "connect.server.request"
+	svc := defaultServiceName(defaultServerServiceName)
+	cfg.serviceName = func() string { return svc }
+	cfg.spanName = serverSpanName
+	cfg.appsec = boolEnv(envAppSecEnabled, false)
+	cfg.appsecBlockingCode = connect.CodePermissionDenied
+	cfg.apiSecurity = boolEnv(envAPISecurityEnabled, true)
 	defaults(cfg)
 }
 
 func clientDefaults(cfg *config) {
-	cfg.serviceName = func() string { return defaultClientServiceName }
-	cfg.spanName = "connect.client.request"
+	svc := defaultServiceName(defaultClientServiceName)
+	cfg.serviceName = func() string { return svc }
+	cfg.spanName = clientSpanName
+	cfg.isClient = true
 	defaults(cfg)
 }
 
-// WithService sets the given service name for the intercepted client.
+// WithService sets the given service name for the intercepted client or server.
+// The tracer tags spans with _dd.base_service when name differs from its own
+// service.
 func WithService(name string) Option {
 	return func(cfg *config) {
 		cfg.serviceName = func() string { return name }
 	}
 }
 
-// WithStreamCalls enables or disables tracing of streaming calls. This option does not apply to the
-// stats handler.
+// WithServiceNameFunc sets a function computing the service name of each call
+// from its spec and request headers, e.g. to split a gateway's spans by tenant.
+// When fn returns an empty string the service set by WithService, or the
+// default one, is used.
+func WithServiceNameFunc(fn func(spec connect.Spec, header http.Header) string) Option {
+	return func(cfg *config) {
+		cfg.serviceNameFunc = fn
+	}
+}
+
+// WithResourceNameFunc sets a function computing the resource name of each call,
+// which defaults to spec.Procedure. req is nil for streaming calls and their
+// messages. When fn returns an empty string the procedure is used.
+func WithResourceNameFunc(fn func(spec connect.Spec, req connect.AnyRequest) string) Option {
+	return func(cfg *config) {
+		cfg.resourceNameFunc = fn
+	}
+}
+
+// WithStreamCalls enables or disables tracing of streaming calls.
 func WithStreamCalls(enabled bool) Option {
 	return func(cfg *config) {
 		cfg.traceStreamCalls = enabled
 	}
 }
 
-// WithStreamMessages enables or disables tracing of streaming messages. This option does not apply
-// to the stats handler.
+// WithStreamMessages enables or disables tracing of streaming messages.
 func WithStreamMessages(enabled bool) Option {
 	return func(cfg *config) {
 		cfg.traceStreamMessages = enabled
@@ -109,49 +278,131 @@ func NonErrorCodes(cs ...connect.Code) InterceptorOption {
 	}
 }
 
-// WithAnalytics enables Trace Analytics for all started spans.
+// WithEnabled specifies whether the interceptor traces calls. It defaults to
+// DD_TRACE_CONNECT_ENABLED, or true.
+func WithEnabled(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.enabled = enabled
+	}
+}
+
+// WithAnalytics enables Trace Analytics for all started spans. WithAnalytics(false)
+// removes the rate set by DD_TRACE_CONNECT_ANALYTICS_ENABLED or previous options.
 func WithAnalytics(on bool) Option {
 	return func(cfg *config) {
 		if on {
-			WithSpanOptions(tracer.AnalyticsRate(1.0))(cfg)
+			cfg.setAnalyticsRate(tracer.AnalyticsRate(1.0))
+		} else {
+			cfg.setAnalyticsRate(nil)
 		}
 	}
 }
 
 // WithAnalyticsRate sets the sampling rate for Trace Analytics events
 // correlated to started spans.
+// Rates outside [0, 1] are ignored.
 func WithAnalyticsRate(rate float64) Option {
 	return func(cfg *config) {
 		if rate >= 0.0 && rate <= 1.0 {
-			WithSpanOptions(tracer.AnalyticsRate(rate))(cfg)
+			cfg.setAnalyticsRate(tracer.AnalyticsRate(rate))
+		} else {
+			cfg.errs = append(cfg.errs, fmt.Errorf("WithAnalyticsRate: rate %v is outside [0, 1]", rate))
 		}
 	}
 }
 
+// setAnalyticsRate replaces the analytics rate option of cfg's span options
+// with opt, or removes it when opt is nil.
+func (cfg *config) setAnalyticsRate(opt tracer.StartSpanOption) {
+	if cfg.analyticsOpt > 0 {
+		cfg.spanOpts = slices.Delete(slices.Clone(cfg.spanOpts), cfg.analyticsOpt-1, cfg.analyticsOpt)
+		cfg.analyticsOpt = 0
+	}
+	if opt != nil {
+		cfg.spanOpts = append(cfg.spanOpts, opt)
+		cfg.analyticsOpt = len(cfg.spanOpts)
+	}
+}
+
 // WithIgnoredMethods specifies full methods to be ignored by the server side interceptor.
-// When an incoming request's full method is in ms, no spans will be created.
+// When an incoming request's full method is in ms, no spans will be created. Methods
+// may be glob patterns, as in WithUntracedMethods. Client interceptors keep
+// honoring it, as they always have, until it is removed.
 //
 // Deprecated: This is deprecated in favor of WithUntracedMethods which applies to both
 // the server side and client side interceptors.
 func WithIgnoredMethods(ms ...string) Option {
-	ims := make(map[string]struct{}, len(ms))
-	for _, e := range ms {
-		ims[e] = struct{}{}
-	}
+	ims, patterns := splitMethods(ms)
 	return func(cfg *config) {
 		cfg.ignoredMethods = ims
+		cfg.ignoredPatterns = patterns
 	}
 }
 
 // WithUntracedMethods specifies full methods to be ignored by the server side and client
 // side interceptors. When a request's full method is in ms, no spans will be created.
+// Methods may be glob patterns in which * matches any sequence of characters, e.g.
+// "/grpc.health.v1.Health/*", "/grpc.reflection.*" or "*/Ping".
 func WithUntracedMethods(ms ...string) Option {
-	ums := make(map[string]struct{}, len(ms))
-	for _, e := range ms {
-		ums[e] = struct{}{}
-	}
+	ums, patterns := splitMethods(ms)
 	return func(cfg *config) {
 		cfg.untracedMethods = ums
+		cfg.untracedPatterns = patterns
+	}
+}
+
+// WithIgnoreInfraMethods specifies whether the gRPC health checking and server
+// reflection procedures, as served by connectrpc.com/grpchealth and
+// connectrpc.com/grpcreflect, are left untraced. It is enabled by default.
+func WithIgnoreInfraMethods(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.ignoreInfraMethods = enabled
+	}
+}
+
+// WithUntracedMethodsRegexp specifies regular expressions matching full methods to be
+// ignored by the server side and client side interceptors, in addition to the ones
+// given to WithUntracedMethods.
+func WithUntracedMethodsRegexp(res ...*regexp.Regexp) Option {
+	return func(cfg *config) {
+		if slices.Contains(res, nil) {
+			cfg.errs = append(cfg.errs, errors.New("WithUntracedMethodsRegexp: nil regular expression"))
+			res = slices.DeleteFunc(slices.Clone(res), func(re *regexp.Regexp) bool { return re == nil })
+		}
+		cfg.untracedRegexps = res
+	}
+}
+
+// WithTracedMethods restricts tracing to the given full methods, which may be glob
+// patterns as in WithUntracedMethods. Methods which are also untraced or ignored are
+// not traced. WithTracedMethods() with no methods does nothing.
+func WithTracedMethods(ms ...string) Option {
+	tms, patterns := splitMethods(ms)
+	return func(cfg *config) {
+		if len(ms) == 0 {
+			return
+		}
+		cfg.tracedMethods = tms
+		cfg.tracedPatterns = patterns
+	}
+}
+
+// WithTracedMethodsRegexp restricts tracing to the full methods matching the given
+// regular expressions, in addition to the ones given to WithTracedMethods.
+// WithTracedMethodsRegexp() with no regular expressions does nothing.
+func WithTracedMethodsRegexp(res ...*regexp.Regexp) Option {
+	return func(cfg *config) {
+		if slices.Contains(res, nil) {
+			cfg.errs = append(cfg.errs, errors.New("WithTracedMethodsRegexp: nil regular expression"))
+			res = slices.DeleteFunc(slices.Clone(res), func(re *regexp.Regexp) bool { return re == nil })
+		}
+		if len(res) == 0 {
+			return
+		}
+		if cfg.tracedMethods == nil {
+			cfg.tracedMethods = map[string]struct{}{}
+		}
+		cfg.tracedRegexps = res
 	}
 }
 
@@ -163,12 +414,26 @@ func WithMetadataTags() Option {
 	}
 }
 
+// metadataTags is WithMetadataTags, which can also disable the tags, for
+// Control and Remote Configuration.
+func metadataTags(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.withMetadataTags = enabled
+	}
+}
+
 // WithIgnoredMetadata specifies keys to be ignored while tracing the metadata. Must be used
-// in conjunction with WithMetadataTags.
+// in conjunction with WithMetadataTags. Keys are trimmed and lowercased, as header
+// names are lowercased before being matched.
 func WithIgnoredMetadata(ms ...string) Option {
 	return func(cfg *config) {
 		for _, e := range ms {
-			cfg.ignoredMetadata[e] = struct{}{}
+			k := strings.ToLower(strings.TrimSpace(e))
+			if k == "" {
+				cfg.errs = append(cfg.errs, fmt.Errorf("WithIgnoredMetadata: empty key %q", e))
+				continue
+			}
+			cfg.ignoredMetadata[k] = struct{}{}
 		}
 	}
 }
@@ -181,6 +446,24 @@ func WithRequestTags() Option {
 	}
 }
 
+// requestTags is WithRequestTags, which can also disable the tag, for Control
+// and Remote Configuration.
+func requestTags(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.withRequestTags = enabled
+	}
+}
+
+// WithRequestFieldTags specifies request message fields to be added to spans as
+// connect.request.field.<path> tags. Each path is a dot-separated list of field names,
+// such as "user.id". Methods can also select fields with the tag_request_fields
+// field of the dd.trace.v1.method_options annotation.
+func WithRequestFieldTags(paths ...string) Option {
+	return func(cfg *config) {
+		cfg.requestFieldTags = paths
+	}
+}
+
 // WithCustomTag will attach the value to the span tagged by the key.
 func WithCustomTag(key string, value interface{}) Option {
 	return func(cfg *config) {
@@ -198,3 +481,81 @@ func WithSpanOptions(opts ...tracer.StartSpanOption) Option {
 		cfg.spanOpts = append(cfg.spanOpts, opts...)
 	}
 }
+
+// WithPanicRecovery enables recovering from panics raised by server handlers.
+// The recovered panic is recorded on the span with error.type "panic", the
+// panic message and the stack, and the span is finished. fn converts the panic
+// value into the error returned to the client; when fn is nil or returns nil,
+// a connect.CodeInternal error is returned instead.
+func WithPanicRecovery(fn func(any) error) ServerOption {
+	return serverOption("WithPanicRecovery", func(cfg *config) {
+		cfg.recoverPanics = true
+		cfg.panicHandler = fn
+	})
+}
+
+// WithRepanic specifies whether a panic recovered by WithPanicRecovery should be
+// raised again once the span has been finished, for services which prefer to
+// crash. Must be used in conjunction with WithPanicRecovery.
+func WithRepanic(enabled bool) ServerOption {
+	return serverOption("WithRepanic", func(cfg *config) {
+		cfg.repanic = enabled
+	})
+}
+
+// WithStartHook adds fn to the functions called when the span of a unary call
+// has been started and tagged, before the call proceeds, e.g. to tag the span
+// with the tenant or user of the authenticated request. ctx carries the span.
+func WithStartHook(fn func(ctx context.Context, span *tracer.Span, req connect.AnyRequest)) Option {
+	return func(cfg *config) {
+		cfg.startHooks = append(cfg.startHooks, fn)
+	}
+}
+
+// WithFinishHook adds fn to the functions called with the response and error of
+// a unary call before its span is finished. resp is nil when err is not nil,
+// including the error of a panic recovered with WithPanicRecovery.
+func WithFinishHook(fn func(ctx context.Context, span *tracer.Span, resp connect.AnyResponse, err error)) Option {
+	return func(cfg *config) {
+		cfg.finishHooks = append(cfg.finishHooks, fn)
+	}
+}
+
+// WithStreamStartHook adds fn to the functions called when the span of a
+// streaming handler call has been started and tagged, before the handler runs.
+// It requires WithStreamCalls, which is enabled by default.
+func WithStreamStartHook(fn func(ctx context.Context, span *tracer.Span, conn connect.StreamingHandlerConn)) ServerOption {
+	return serverOption("WithStreamStartHook", func(cfg *config) {
+		cfg.streamStartHooks = append(cfg.streamStartHooks, fn)
+	})
+}
+
+// WithStreamFinishHook adds fn to the functions called with the error returned
+// by a streaming handler before the span of the call is finished.
+func WithStreamFinishHook(fn func(ctx context.Context, span *tracer.Span, conn connect.StreamingHandlerConn, err error)) ServerOption {
+	return serverOption("WithStreamFinishHook", func(cfg *config) {
+		cfg.streamFinishHooks = append(cfg.streamFinishHooks, fn)
+	})
+}
+
+// WithContextTagsBaggage specifies whether the client interceptor forwards the
+// tags set with ContextWithTags to the server as baggage items, propagated with
+// the trace context. Values are formatted with fmt.Sprint.
+func WithContextTagsBaggage(enabled bool) ClientOption {
+	return clientOption("WithContextTagsBaggage", func(cfg *config) {
+		cfg.contextTagsBaggage = enabled
+	})
+}
+
+// WithProcedureOptions applies opts on top of the other options for calls to
+// procedure only, e.g. to enable WithRequestTags for a single endpoint or
+// WithStreamMessages(false) for a single stream. procedure may be a glob
+// pattern as in WithUntracedMethods, such as "/example.v1.ExampleService/*"
+// for a whole service. When several procedure options match a procedure they
+// are applied in the order given.
+func WithProcedureOptions(procedure string, opts ...AnyOption) Option {
+	po := newProcedureOptions(procedure, opts)
+	return func(cfg *config) {
+		cfg.procedureOpts = append(cfg.procedureOpts, po)
+	}
+}
@@ -1,10 +1,13 @@
 package connect
 
 import (
+	"context"
 	"testing"
 
 	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 func TestDefaults(t *testing.T) {
@@ -23,6 +26,10 @@ func TestDefaults(t *testing.T) {
 		t.Error("expected CodeCanceled to be in nonErrorCodes by default")
 	}
 
+	if !cfg.ignoreInfraMethods {
+		t.Error("expected ignoreInfraMethods to be true by default")
+	}
+
 	if cfg.ignoredMetadata == nil {
 		t.Error("expected ignoredMetadata to be initialized")
 	}
@@ -262,6 +269,16 @@ func TestWithIgnoredMethods(t *testing.T) {
 	}
 }
 
+func TestWithIgnoredMethodsClient(t *testing.T) {
+	cfg := newConfig(clientDefaults, []AnyOption{WithIgnoredMethods("/a.B/C")})
+	if err := cfg.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if cfg.isTraced("/a.B/C") {
+		t.Error("expected client interceptors to keep honoring WithIgnoredMethods")
+	}
+}
+
 func TestWithUntracedMethods(t *testing.T) {
 	methods := []string{"/test.Service/Method1", "/test.Service/Method2"}
 	cfg := &config{}
@@ -305,6 +322,29 @@ func TestWithIgnoredMetadata(t *testing.T) {
 	}
 }
 
+func TestWithIgnoredMetadataMixedCase(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	call := NewServerInterceptor(WithMetadataTags(), WithIgnoredMetadata("X-Secret")).WrapUnary(
+		func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		})
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("X-Secret", "tok")
+	req.Header().Set("X-Other", "v")
+	if _, err := call(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	span := mt.FinishedSpans()[0]
+	if got := span.Tag(tagMetadataPrefix + "x-secret.0"); got != nil {
+		t.Errorf("expected the mixed-case ignored key to hide the header, got %v", got)
+	}
+	if got := span.Tag(tagMetadataPrefix + "x-other.0"); got == nil {
+		t.Error("expected the other headers to be tagged")
+	}
+}
+
 func TestWithRequestTags(t *testing.T) {
 	cfg := &config{}
 	option := WithRequestTags()
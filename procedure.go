@@ -0,0 +1,86 @@
+package connect
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"connectrpc.com/connect"
+)
+
+// procedureOptions holds the options given to WithProcedureOptions for the
+// procedures matching either procedure or pattern.
+type procedureOptions struct {
+	procedure string
+	pattern   *regexp.Regexp
+	opts      []AnyOption
+}
+
+func (po procedureOptions) match(procedure string) bool {
+	if po.pattern != nil {
+		return po.pattern.MatchString(procedure)
+	}
+	return po.procedure == procedure
+}
+
+// forProcedure returns the configuration for calls to spec: cfg itself when
+// neither a dd.trace.v1.method_options annotation nor WithProcedureOptions apply
+// to it, or a copy of cfg with the annotation then the matching procedure
+// options applied. The result is computed once per procedure.
+func (cfg *config) forProcedure(spec connect.Spec) *config {
+	if cfg.procedureCache == nil {
+		return cfg
+	}
+	procedure := spec.Procedure
+	if c, ok := cfg.procedureCache.Load(procedure); ok {
+		return c.(*config)
+	}
+	resolved := cfg
+	if mo := methodOptions(spec); mo != nil {
+		resolved = cfg.clone()
+		resolved.applyMethodOptions(mo)
+	}
+	for _, po := range cfg.procedureOpts {
+		if !po.match(procedure) {
+			continue
+		}
+		if resolved == cfg {
+			resolved = cfg.clone()
+		}
+		for _, opt := range po.opts {
+			resolved.apply(opt)
+		}
+	}
+	c, _ := cfg.procedureCache.LoadOrStore(procedure, resolved)
+	return c.(*config)
+}
+
+// clone returns a copy of cfg which options can modify without affecting cfg.
+// Maps modified in place by options are copied, and the caches are reset.
+func (cfg *config) clone() *config {
+	c := *cfg
+	c.ignoredMetadata = maps.Clone(cfg.ignoredMetadata)
+	c.tags = maps.Clone(cfg.tags)
+	c.spanOpts = slices.Clip(cfg.spanOpts)
+	c.errs = slices.Clip(cfg.errs)
+	c.startHooks = slices.Clip(cfg.startHooks)
+	c.finishHooks = slices.Clip(cfg.finishHooks)
+	c.streamStartHooks = slices.Clip(cfg.streamStartHooks)
+	c.streamFinishHooks = slices.Clip(cfg.streamFinishHooks)
+	c.procedureOpts = nil
+	c.procedureCache = nil
+	c.tracedCache = new(sync.Map)
+	return &c
+}
+
+// newProcedureOptions returns the procedureOptions for procedure, which may be
+// a glob pattern as in WithUntracedMethods.
+func newProcedureOptions(procedure string, opts []AnyOption) procedureOptions {
+	po := procedureOptions{procedure: procedure, opts: opts}
+	if strings.Contains(procedure, "*") {
+		po.pattern = globRegexp(procedure)
+	}
+	return po
+}
@@ -0,0 +1,56 @@
+package connect
+
+import (
+	"testing"
+
+	"connectrpc.com/connect"
+)
+
+func TestWithProcedureOptions(t *testing.T) {
+	cfg := new(config)
+	serverDefaults(cfg)
+	WithCustomTag("team", "core")(cfg)
+	WithProcedureOptions("/test.Service/Debug", WithRequestTags(), WithCustomTag("debug", true))(cfg)
+	WithProcedureOptions("/test.Service/*", NonErrorCodes(connect.CodeNotFound))(cfg)
+	WithProcedureOptions("/test.Service/Firehose", WithStreamMessages(false))(cfg)
+
+	debug := cfg.forProcedure(connect.Spec{Procedure: "/test.Service/Debug"})
+	if debug == cfg {
+		t.Fatal("expected a dedicated config for /test.Service/Debug")
+	}
+	if !debug.withRequestTags {
+		t.Error("expected request tags to be enabled for /test.Service/Debug")
+	}
+	if debug.tags["debug"] != true || debug.tags["team"] != "core" {
+		t.Errorf("expected custom tags to be merged, got %v", debug.tags)
+	}
+	if !debug.nonErrorCodes[connect.CodeNotFound] {
+		t.Error("expected service wide options to apply to /test.Service/Debug")
+	}
+	if got := cfg.forProcedure(connect.Spec{Procedure: "/test.Service/Debug"}); got != debug {
+		t.Error("expected the resolved config to be cached")
+	}
+
+	firehose := cfg.forProcedure(connect.Spec{Procedure: "/test.Service/Firehose"})
+	if firehose.traceStreamMessages {
+		t.Error("expected stream messages to be disabled for /test.Service/Firehose")
+	}
+	if firehose.withRequestTags {
+		t.Error("expected request tags to stay disabled for /test.Service/Firehose")
+	}
+
+	if got := cfg.forProcedure(connect.Spec{Procedure: "/other.Service/Method"}); got != cfg {
+		t.Error("expected the base config for procedures without overrides")
+	}
+
+	// the base config is left untouched
+	if cfg.withRequestTags || !cfg.traceStreamMessages {
+		t.Error("expected procedure options not to modify the base config")
+	}
+	if _, ok := cfg.tags["debug"]; ok {
+		t.Error("expected procedure custom tags not to leak into the base config")
+	}
+	if cfg.nonErrorCodes[connect.CodeNotFound] {
+		t.Error("expected procedure non-error codes not to leak into the base config")
+	}
+}
@@ -0,0 +1,174 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: dd/trace/v1/options.proto
+
+package tracev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// MethodOptions configures how the Datadog connect interceptors trace a method.
+//
+//	rpc Check(CheckRequest) returns (CheckResponse) {
+//	  option (dd.trace.v1.method_options) = {skip: true};
+//	}
+type MethodOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Disables tracing of the method.
+	Skip bool `protobuf:"varint,1,opt,name=skip,proto3" json:"skip,omitempty"`
+	// Request message fields, given as dot-separated field name paths, to tag
+	// on the span as connect.request.field.<path>.
+	TagRequestFields []string `protobuf:"bytes,2,rep,name=tag_request_fields,json=tagRequestFields,proto3" json:"tag_request_fields,omitempty"`
+	// Overrides the span resource name, which defaults to the procedure.
+	ResourceName  string `protobuf:"bytes,3,opt,name=resource_name,json=resourceName,proto3" json:"resource_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MethodOptions) Reset() {
+	*x = MethodOptions{}
+	mi := &file_dd_trace_v1_options_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MethodOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MethodOptions) ProtoMessage() {}
+
+func (x *MethodOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_dd_trace_v1_options_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MethodOptions.ProtoReflect.Descriptor instead.
+func (*MethodOptions) Descriptor() ([]byte, []int) {
+	return file_dd_trace_v1_options_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MethodOptions) GetSkip() bool {
+	if x != nil {
+		return x.Skip
+	}
+	return false
+}
+
+func (x *MethodOptions) GetTagRequestFields() []string {
+	if x != nil {
+		return x.TagRequestFields
+	}
+	return nil
+}
+
+func (x *MethodOptions) GetResourceName() string {
+	if x != nil {
+		return x.ResourceName
+	}
+	return ""
+}
+
+var file_dd_trace_v1_options_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*MethodOptions)(nil),
+		Field:         50510,
+		Name:          "dd.trace.v1.method_options",
+		Tag:           "bytes,50510,opt,name=method_options",
+		Filename:      "dd/trace/v1/options.proto",
+	},
+}
+
+// Extension fields to descriptorpb.MethodOptions.
+var (
+	// Tracing configuration of the method.
+	//
+	// optional dd.trace.v1.MethodOptions method_options = 50510;
+	E_MethodOptions = &file_dd_trace_v1_options_proto_extTypes[0]
+)
+
+var File_dd_trace_v1_options_proto protoreflect.FileDescriptor
+
+const file_dd_trace_v1_options_proto_rawDesc = "" +
+	"\n" +
+	"\x19dd/trace/v1/options.proto\x12\vdd.trace.v1\x1a google/protobuf/descriptor.proto\"v\n" +
+	"\rMethodOptions\x12\x12\n" +
+	"\x04skip\x18\x01 \x01(\bR\x04skip\x12,\n" +
+	"\x12tag_request_fields\x18\x02 \x03(\tR\x10tagRequestFields\x12#\n" +
+	"\rresource_name\x18\x03 \x01(\tR\fresourceName:c\n" +
+	"\x0emethod_options\x12\x1e.google.protobuf.MethodOptions\x18Ί\x03 \x01(\v2\x1a.dd.trace.v1.MethodOptionsR\rmethodOptionsBHZFgithub.com/cskd8/dd-trace-go-contrib-connect/proto/dd/trace/v1;tracev1b\x06proto3"
+
+var (
+	file_dd_trace_v1_options_proto_rawDescOnce sync.Once
+	file_dd_trace_v1_options_proto_rawDescData []byte
+)
+
+func file_dd_trace_v1_options_proto_rawDescGZIP() []byte {
+	file_dd_trace_v1_options_proto_rawDescOnce.Do(func() {
+		file_dd_trace_v1_options_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dd_trace_v1_options_proto_rawDesc), len(file_dd_trace_v1_options_proto_rawDesc)))
+	})
+	return file_dd_trace_v1_options_proto_rawDescData
+}
+
+var file_dd_trace_v1_options_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_dd_trace_v1_options_proto_goTypes = []any{
+	(*MethodOptions)(nil),              // 0: dd.trace.v1.MethodOptions
+	(*descriptorpb.MethodOptions)(nil), // 1: google.protobuf.MethodOptions
+}
+var file_dd_trace_v1_options_proto_depIdxs = []int32{
+	1, // 0: dd.trace.v1.method_options:extendee -> google.protobuf.MethodOptions
+	0, // 1: dd.trace.v1.method_options:type_name -> dd.trace.v1.MethodOptions
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	1, // [1:2] is the sub-list for extension type_name
+	0, // [0:1] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_dd_trace_v1_options_proto_init() }
+func file_dd_trace_v1_options_proto_init() {
+	if File_dd_trace_v1_options_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dd_trace_v1_options_proto_rawDesc), len(file_dd_trace_v1_options_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 1,
+			NumServices:   0,
+		},
+		GoTypes:           file_dd_trace_v1_options_proto_goTypes,
+		DependencyIndexes: file_dd_trace_v1_options_proto_depIdxs,
+		MessageInfos:      file_dd_trace_v1_options_proto_msgTypes,
+		ExtensionInfos:    file_dd_trace_v1_options_proto_extTypes,
+	}.Build()
+	File_dd_trace_v1_options_proto = out.File
+	file_dd_trace_v1_options_proto_goTypes = nil
+	file_dd_trace_v1_options_proto_depIdxs = nil
+}
@@ -0,0 +1,562 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+)
+
+const (
+	envEnv                      = "DD_ENV"
+	envAgentURL                 = "DD_TRACE_AGENT_URL"
+	envAgentHost                = "DD_AGENT_HOST"
+	envAgentPort                = "DD_TRACE_AGENT_PORT"
+	envRemoteConfigPollInterval = "DD_REMOTE_CONFIG_POLL_INTERVAL_SECONDS"
+
+	defaultAgentHost                = "localhost"
+	defaultAgentPort                = "8126"
+	defaultRemoteConfigPollInterval = 5 * time.Second
+
+	// remoteConfigProduct is the Remote Configuration product carrying the
+	// connect tracing settings, in the lib_config.connect namespace of its
+	// configurations.
+	remoteConfigProduct = state.ProductAPMTracing
+)
+
+// remoteSettings are the connect tracing settings received through Remote
+// Configuration. Unset fields leave the interceptor's own options in effect.
+type remoteSettings struct {
+	Enabled         *bool    `json:"enabled,omitempty"`
+	StreamCalls     *bool    `json:"stream_calls,omitempty"`
+	StreamMessages  *bool    `json:"stream_messages,omitempty"`
+	RequestTags     *bool    `json:"request_tags,omitempty"`
+	MetadataTags    *bool    `json:"metadata_tags,omitempty"`
+	IgnoredMetadata []string `json:"ignored_metadata,omitempty"`
+	UntracedMethods []string `json:"untraced_methods,omitempty"`
+	AnalyticsRate   *float64 `json:"analytics_rate,omitempty"`
+}
+
+// merge overrides the fields of s which are set in o.
+func (s *remoteSettings) merge(o *remoteSettings) {
+	if o.Enabled != nil {
+		s.Enabled = o.Enabled
+	}
+	if o.StreamCalls != nil {
+		s.StreamCalls = o.StreamCalls
+	}
+	if o.StreamMessages != nil {
+		s.StreamMessages = o.StreamMessages
+	}
+	if o.RequestTags != nil {
+		s.RequestTags = o.RequestTags
+	}
+	if o.MetadataTags != nil {
+		s.MetadataTags = o.MetadataTags
+	}
+	if o.IgnoredMetadata != nil {
+		s.IgnoredMetadata = o.IgnoredMetadata
+	}
+	if o.UntracedMethods != nil {
+		s.UntracedMethods = o.UntracedMethods
+	}
+	if o.AnalyticsRate != nil {
+		s.AnalyticsRate = o.AnalyticsRate
+	}
+}
+
+// options returns the options applying s on top of the interceptor's options.
+func (s *remoteSettings) options() []Option {
+	var opts []Option
+	if s.Enabled != nil {
+		opts = append(opts, WithEnabled(*s.Enabled))
+	}
+	if s.StreamCalls != nil {
+		opts = append(opts, WithStreamCalls(*s.StreamCalls))
+	}
+	if s.StreamMessages != nil {
+		opts = append(opts, WithStreamMessages(*s.StreamMessages))
+	}
+	if s.RequestTags != nil {
+		opts = append(opts, requestTags(*s.RequestTags))
+	}
+	if s.MetadataTags != nil {
+		opts = append(opts, metadataTags(*s.MetadataTags))
+	}
+	if s.IgnoredMetadata != nil {
+		// header names are matched lowercased
+		opts = append(opts, WithIgnoredMetadata(normalizeList(s.IgnoredMetadata, strings.ToLower)...))
+	}
+	if s.UntracedMethods != nil {
+		opts = append(opts, WithUntracedMethods(normalizeList(s.UntracedMethods, nil)...))
+	}
+	if s.AnalyticsRate != nil {
+		opts = append(opts, WithAnalyticsRate(*s.AnalyticsRate))
+	}
+	return opts
+}
+
+// normalizeList returns the non-empty values of vs, trimmed and then mapped
+// through fn when it is not nil, as the environment variables are read.
+func normalizeList(vs []string, fn func(string) string) []string {
+	values := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if fn != nil {
+			v = fn(v)
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// apmTracingConfig is the part of an APM_TRACING configuration read by the
+// integration.
+type apmTracingConfig struct {
+	ServiceTarget *struct {
+		Service string `json:"service"`
+		Env     string `json:"env"`
+	} `json:"service_target,omitempty"`
+	LibConfig struct {
+		TracingEnabled *bool           `json:"tracing_enabled,omitempty"`
+		Connect        *remoteSettings `json:"connect,omitempty"`
+	} `json:"lib_config"`
+}
+
+// settings returns the connect tracing settings of c, or nil when c carries
+// none. The tracing_enabled setting written by the Datadog UI applies when the
+// connect namespace does not set enabled.
+func (c *apmTracingConfig) settings() *remoteSettings {
+	s := c.LibConfig.Connect
+	if c.LibConfig.TracingEnabled == nil {
+		return s
+	}
+	if s == nil {
+		s = new(remoteSettings)
+	}
+	if s.Enabled == nil {
+		s.Enabled = c.LibConfig.TracingEnabled
+	}
+	return s
+}
+
+// RemoteConfig applies the connect tracing settings received through Datadog
+// Remote Configuration to the interceptors of the registered Controls, so the
+// Datadog UI can change them on running services. The settings are read from
+// the lib_config.connect namespace of the APM_TRACING configurations
+// targeting the service:
+//
+//	{
+//	  "service_target": {"service": "my-service", "env": "prod"},
+//	  "lib_config": {
+//	    "connect": {
+//	      "enabled": true,
+//	      "stream_calls": true,
+//	      "stream_messages": false,
+//	      "request_tags": true,
+//	      "metadata_tags": true,
+//	      "ignored_metadata": ["authorization"],
+//	      "untraced_methods": ["/example.v1.ExampleService/*"],
+//	      "analytics_rate": 0.5
+//	    }
+//	  }
+//	}
+//
+// The Datadog UI does not write the lib_config.connect namespace: it only sets
+// the tracing_enabled setting of lib_config, which is applied as enabled. The
+// other settings take effect only for configurations carrying the namespace.
+//
+// RemoteConfig polls the Agent as a client of its own which does not identify
+// itself as a tracer: dd-trace-go already reports the service's tracer, and a
+// second one would be listed as another instance of the service. The Agent
+// thus sends the configurations of every service, and RemoteConfig applies
+// those targeting its own. It acknowledges every configuration it can parse,
+// whether or not it carries connect settings. Each change of the settings is
+// logged.
+type RemoteConfig struct {
+	endpoint string
+	interval time.Duration
+	client   *http.Client
+	service  string
+	env      string
+	clientID string
+
+	mu         sync.Mutex // guards below fields
+	repository *state.Repository
+	lastError  error
+	controls   []*Control
+	settings   *remoteSettings
+
+	run     sync.Mutex // guards below fields
+	started bool
+	stopped bool
+	ctx     context.Context // canceled by Stop
+	cancel  context.CancelFunc
+	done    chan struct{} // closed when the polling goroutine returns
+}
+
+// RemoteConfigOption configures a RemoteConfig.
+type RemoteConfigOption func(*RemoteConfig)
+
+// WithAgentURL sets the URL of the Datadog Agent polled for configurations. It
+// defaults to DD_TRACE_AGENT_URL, or to DD_AGENT_HOST and DD_TRACE_AGENT_PORT.
+func WithAgentURL(url string) RemoteConfigOption {
+	return func(rc *RemoteConfig) {
+		rc.endpoint = url + "/v0.7/config"
+	}
+}
+
+// WithPollInterval sets the interval between two polls of the Datadog Agent. It
+// defaults to DD_REMOTE_CONFIG_POLL_INTERVAL_SECONDS, or 5 seconds.
+func WithPollInterval(d time.Duration) RemoteConfigOption {
+	return func(rc *RemoteConfig) {
+		rc.interval = d
+	}
+}
+
+// WithRemoteConfigService sets the service and environment the configurations
+// must target. They default to DD_SERVICE and DD_ENV.
+func WithRemoteConfigService(service, env string) RemoteConfigOption {
+	return func(rc *RemoteConfig) {
+		rc.service = service
+		rc.env = env
+	}
+}
+
+// NewRemoteConfig returns a RemoteConfig polling the Datadog Agent once Start
+// is called.
+func NewRemoteConfig(opts ...RemoteConfigOption) (*RemoteConfig, error) {
+	repository, err := state.NewUnverifiedRepository()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	rc := &RemoteConfig{
+		endpoint:   agentURL() + "/v0.7/config",
+		interval:   defaultRemoteConfigPollInterval,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		service:    os.Getenv(envService),
+		env:        os.Getenv(envEnv),
+		clientID:   newClientID(),
+		repository: repository,
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	if s, err := strconv.ParseFloat(os.Getenv(envRemoteConfigPollInterval), 64); err == nil && s > 0 {
+		rc.interval = time.Duration(s * float64(time.Second))
+	}
+	for _, opt := range opts {
+		opt(rc)
+	}
+	return rc, nil
+}
+
+// agentURL returns the Datadog Agent URL configured in the environment.
+func agentURL() string {
+	if u := os.Getenv(envAgentURL); u != "" {
+		return u
+	}
+	host, port := os.Getenv(envAgentHost), os.Getenv(envAgentPort)
+	if host == "" {
+		host = defaultAgentHost
+	}
+	if port == "" {
+		port = defaultAgentPort
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+func newClientID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Register applies the received settings to the interceptor of ctl, now and on
+// every later change.
+func (rc *RemoteConfig) Register(ctl *Control) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.controls = append(rc.controls, ctl)
+	if rc.settings != nil {
+		ctl.setRemote(rc.settings.options())
+	}
+}
+
+// Start starts polling the Datadog Agent in the background. Calling it again,
+// or after Stop, has no effect.
+func (rc *RemoteConfig) Start() {
+	rc.run.Lock()
+	defer rc.run.Unlock()
+	if rc.started || rc.stopped {
+		return
+	}
+	rc.started = true
+	go func() {
+		defer close(rc.done)
+		ticker := time.NewTicker(rc.interval)
+		defer ticker.Stop()
+		for {
+			rc.poll()
+			select {
+			case <-ticker.C:
+			case <-rc.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling the Datadog Agent, canceling the request in flight, and
+// waits for the polling to return. The last received settings stay in effect.
+func (rc *RemoteConfig) Stop() {
+	rc.run.Lock()
+	rc.stopped = true
+	started := rc.started
+	rc.run.Unlock()
+	rc.cancel()
+	if started {
+		<-rc.done
+	}
+}
+
+// poll fetches the configurations from the Datadog Agent and applies them.
+func (rc *RemoteConfig) poll() {
+	rc.mu.Lock()
+	body, err := rc.newRequest()
+	rc.mu.Unlock()
+	if err != nil {
+		logError("connect: remote configuration: could not build the request: %v", err)
+		return
+	}
+	req, err := http.NewRequestWithContext(rc.ctx, http.MethodPost, rc.endpoint, body)
+	if err != nil {
+		logError("connect: remote configuration: could not build the request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	var update rcResponse
+	if err := json.Unmarshal(raw, &update); err != nil {
+		logError("connect: remote configuration: could not parse the response: %v", err)
+		return
+	}
+	// an update without TUF targets carries no change
+	if len(update.Targets) == 0 {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.lastError = rc.applyUpdate(&update)
+}
+
+// applyUpdate updates the repository and, when the configurations of
+// remoteConfigProduct changed, applies their settings to the controls.
+func (rc *RemoteConfig) applyUpdate(update *rcResponse) error {
+	files := make(map[string][]byte, len(update.TargetFiles))
+	for _, f := range update.TargetFiles {
+		files[f.Path] = f.Raw
+	}
+	products, err := rc.repository.Update(state.Update{
+		TUFRoots:      update.Roots,
+		TUFTargets:    update.Targets,
+		TargetFiles:   files,
+		ClientConfigs: update.ClientConfigs,
+	})
+	if err != nil {
+		return fmt.Errorf("repository update error: %w", err)
+	}
+	if !slices.Contains(products, remoteConfigProduct) {
+		return nil
+	}
+
+	configs := rc.repository.GetConfigs(remoteConfigProduct)
+	var settings *remoteSettings
+	for _, path := range slices.Sorted(maps.Keys(configs)) {
+		var c apmTracingConfig
+		if err := json.Unmarshal(configs[path].Config, &c); err != nil {
+			rc.repository.UpdateApplyStatus(path, state.ApplyStatus{State: state.ApplyStateError, Error: err.Error()})
+			continue
+		}
+		// configurations without connect settings, or for other services, are
+		// processed successfully too: reporting them unacknowledged would show
+		// the tracer's own APM_TRACING configurations as pending in the UI
+		rc.repository.UpdateApplyStatus(path, state.ApplyStatus{State: state.ApplyStateAcknowledged})
+		s := c.settings()
+		if s == nil || !rc.targets(&c) {
+			continue
+		}
+		if settings == nil {
+			settings = new(remoteSettings)
+		}
+		settings.merge(s)
+	}
+	rc.apply(settings)
+	return nil
+}
+
+// targets reports whether c targets the service and environment of rc.
+func (rc *RemoteConfig) targets(c *apmTracingConfig) bool {
+	t := c.ServiceTarget
+	if t == nil {
+		return true
+	}
+	return (t.Service == "" || t.Service == "*" || t.Service == rc.service) &&
+		(t.Env == "" || t.Env == "*" || t.Env == rc.env)
+}
+
+// apply applies settings to the controls, logging the change. nil settings
+// restore the interceptors' own options.
+func (rc *RemoteConfig) apply(settings *remoteSettings) {
+	if rc.settings == nil && settings == nil {
+		return
+	}
+	if rc.settings != nil && settings != nil {
+		before, _ := json.Marshal(rc.settings)
+		after, _ := json.Marshal(settings)
+		if bytes.Equal(before, after) {
+			return
+		}
+	}
+	rc.settings = settings
+	var opts []Option
+	if settings != nil {
+		b, _ := json.Marshal(settings)
+		logInfo("connect: remote configuration updated the connect tracing settings: %s", b)
+		opts = settings.options()
+	} else {
+		logInfo("connect: remote configuration removed the connect tracing settings")
+	}
+	for _, ctl := range rc.controls {
+		ctl.setRemote(opts)
+	}
+}
+
+// newRequest returns the body of the request for the configurations of
+// remoteConfigProduct, reporting the state of the repository.
+func (rc *RemoteConfig) newRequest() (io.Reader, error) {
+	s, err := rc.repository.CurrentState()
+	if err != nil {
+		return nil, err
+	}
+	configStates := make([]rcConfigState, 0, len(s.Configs))
+	for _, c := range s.Configs {
+		configStates = append(configStates, rcConfigState{
+			ID:         c.ID,
+			Version:    c.Version,
+			Product:    c.Product,
+			ApplyState: c.ApplyStatus.State,
+			ApplyError: c.ApplyStatus.Error,
+		})
+	}
+	cachedFiles := make([]rcTargetFileMeta, 0, len(s.CachedFiles))
+	for _, f := range s.CachedFiles {
+		hashes := make([]rcTargetFileHash, 0, len(f.Hashes))
+		for alg, h := range f.Hashes {
+			hashes = append(hashes, rcTargetFileHash{Algorithm: alg, Hash: hex.EncodeToString(h)})
+		}
+		cachedFiles = append(cachedFiles, rcTargetFileMeta{Path: f.Path, Length: int64(f.Length), Hashes: hashes})
+	}
+	req := rcRequest{
+		Client: rcClient{
+			State: rcClientState{
+				RootVersion:    max(uint64(s.RootsVersion), 1),
+				TargetsVersion: uint64(s.TargetsVersion),
+				ConfigStates:   configStates,
+				HasError:       rc.lastError != nil,
+			},
+			ID:       rc.clientID,
+			Products: []string{remoteConfigProduct},
+		},
+		CachedTargetFiles: cachedFiles,
+	}
+	if rc.lastError != nil {
+		req.Client.State.Error = rc.lastError.Error()
+	}
+	b, err := json.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// The types below are the JSON encoding of the Datadog Agent's
+// ClientGetConfigsRequest and ClientGetConfigsResponse.
+
+type rcRequest struct {
+	Client            rcClient           `json:"client"`
+	CachedTargetFiles []rcTargetFileMeta `json:"cached_target_files,omitempty"`
+}
+
+type rcClient struct {
+	State    rcClientState `json:"state"`
+	ID       string        `json:"id"`
+	Products []string      `json:"products"`
+}
+
+type rcClientState struct {
+	RootVersion    uint64          `json:"root_version"`
+	TargetsVersion uint64          `json:"targets_version"`
+	ConfigStates   []rcConfigState `json:"config_states,omitempty"`
+	HasError       bool            `json:"has_error,omitempty"`
+	Error          string          `json:"error,omitempty"`
+}
+
+type rcConfigState struct {
+	ID         string           `json:"id"`
+	Version    uint64           `json:"version"`
+	Product    string           `json:"product"`
+	ApplyState state.ApplyState `json:"apply_state,omitempty"`
+	ApplyError string           `json:"apply_error,omitempty"`
+}
+
+type rcTargetFileHash struct {
+	Algorithm string `json:"algorithm"`
+	Hash      string `json:"hash"`
+}
+
+type rcTargetFileMeta struct {
+	Path   string             `json:"path"`
+	Length int64              `json:"length"`
+	Hashes []rcTargetFileHash `json:"hashes"`
+}
+
+type rcResponse struct {
+	Roots         [][]byte       `json:"roots,omitempty"`
+	Targets       []byte         `json:"targets,omitempty"`
+	TargetFiles   []rcTargetFile `json:"target_files,omitempty"`
+	ClientConfigs []string       `json:"client_configs,omitempty"`
+}
+
+type rcTargetFile struct {
+	Path string `json:"path"`
+	Raw  []byte `json:"raw"`
+}
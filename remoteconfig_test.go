@@ -0,0 +1,287 @@
+package connect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+	"github.com/DataDog/dd-trace-go/v2/instrumentation"
+)
+
+// fakeAgent serves the Remote Configuration endpoint of the Datadog Agent with
+// unsigned TUF targets.
+type fakeAgent struct {
+	mu       sync.Mutex
+	version  int
+	configs  map[string]string // config ID -> APM_TRACING configuration
+	requests []rcRequest
+}
+
+func (a *fakeAgent) set(configs map[string]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.version++
+	a.configs = configs
+}
+
+func (a *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var req rcRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.requests = append(a.requests, req)
+	if req.Client.State.TargetsVersion == uint64(a.version) {
+		_, _ = w.Write([]byte("{}"))
+		return
+	}
+
+	targets := map[string]any{}
+	var resp rcResponse
+	for id, c := range a.configs {
+		path := "datadog/2/" + remoteConfigProduct + "/" + id + "/config"
+		sum := sha256.Sum256([]byte(c))
+		targets[path] = map[string]any{
+			"length": len(c),
+			"hashes": map[string]string{"sha256": hex.EncodeToString(sum[:])},
+			"custom": map[string]any{"v": a.version},
+		}
+		resp.TargetFiles = append(resp.TargetFiles, rcTargetFile{Path: path, Raw: []byte(c)})
+		resp.ClientConfigs = append(resp.ClientConfigs, path)
+	}
+	resp.Targets, _ = json.Marshal(map[string]any{
+		"signed": map[string]any{
+			"_type":        "targets",
+			"custom":       map[string]any{"opaque_backend_state": "e30="},
+			"expires":      "2099-01-01T00:00:00Z",
+			"spec_version": "1.0",
+			"targets":      targets,
+			"version":      a.version,
+		},
+		"signatures": []any{},
+	})
+	_ = json.NewEncoder(w).Encode(&resp)
+}
+
+type captureLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *captureLogger) logf(level, format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Debug(msg string, args ...any) { l.logf("DEBUG", msg, args...) }
+func (l *captureLogger) Info(msg string, args ...any)  { l.logf("INFO", msg, args...) }
+func (l *captureLogger) Warn(msg string, args ...any)  { l.logf("WARN", msg, args...) }
+func (l *captureLogger) Error(msg string, args ...any) { l.logf("ERROR", msg, args...) }
+
+// useLogger makes the integration log to l until the end of the test.
+func useLogger(t *testing.T, l instrumentation.Logger) {
+	old := logger
+	logger = l
+	t.Cleanup(func() { logger = old })
+}
+
+func TestRemoteConfig(t *testing.T) {
+	logs := new(captureLogger)
+	useLogger(t, logs)
+
+	agent := new(fakeAgent)
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+
+	rc, err := NewRemoteConfig(WithAgentURL(srv.URL), WithRemoteConfigService("svc", "prod"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, ctl := NewServerInterceptorWithControl(WithStreamMessages(false))
+	rc.Register(ctl)
+
+	agent.set(map[string]string{
+		"connect": `{"lib_config": {"connect": {"request_tags": true, "untraced_methods": ["/svc.Health/*"]}}}`,
+		"other":   `{"service_target": {"service": "other", "env": "prod"}, "lib_config": {"connect": {"metadata_tags": true}}}`,
+		"tracer":  `{"lib_config": {"tracing_sampling_rate": 0.5}}`,
+	})
+	rc.poll()
+
+	cfg := ctl.cfg.Load()
+	if !cfg.withRequestTags {
+		t.Error("expected request tags to be enabled remotely")
+	}
+	if cfg.withMetadataTags {
+		t.Error("expected configurations targeting another service to be ignored")
+	}
+	if cfg.isTraced("/svc.Health/Check") {
+		t.Error("expected untraced methods to be set remotely")
+	}
+	if cfg.traceStreamMessages {
+		t.Error("expected local options to be kept")
+	}
+	if len(logs.msgs) != 1 || !strings.Contains(logs.msgs[0], `"request_tags":true`) {
+		t.Errorf("expected the change to be logged, got %q", logs.msgs)
+	}
+
+	// an unchanged configuration is neither applied nor logged again
+	rc.poll()
+	if len(logs.msgs) != 1 {
+		t.Errorf("expected a single log line, got %q", logs.msgs)
+	}
+
+	agent.set(nil)
+	rc.poll()
+	if ctl.cfg.Load().withRequestTags {
+		t.Error("expected removing the configuration to restore the local options")
+	}
+	if len(logs.msgs) != 2 || !strings.Contains(logs.msgs[1], "removed") {
+		t.Errorf("expected the removal to be logged, got %q", logs.msgs)
+	}
+
+	req := agent.requests[len(agent.requests)-1]
+	if len(req.Client.Products) != 1 || req.Client.Products[0] != remoteConfigProduct {
+		t.Errorf("expected the %s product to be requested, got %v", remoteConfigProduct, req.Client.Products)
+	}
+	if states := agent.requests[1].Client.State.ConfigStates; len(states) != 3 {
+		t.Errorf("expected 3 configuration states, got %+v", states)
+	}
+	for _, s := range agent.requests[1].Client.State.ConfigStates {
+		// including the configurations targeting another service or without
+		// connect settings, which are processed but not applied
+		if s.ApplyState != state.ApplyStateAcknowledged {
+			t.Errorf("expected configuration %s to be acknowledged, got state %d", s.ID, s.ApplyState)
+		}
+	}
+}
+
+func TestRemoteConfigRegisterLate(t *testing.T) {
+	useLogger(t, new(captureLogger))
+
+	agent := new(fakeAgent)
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+
+	rc, err := NewRemoteConfig(WithAgentURL(srv.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	agent.set(map[string]string{"connect": `{"lib_config": {"connect": {"enabled": false}}}`})
+	rc.poll()
+
+	_, ctl := NewClientInterceptorWithControl()
+	rc.Register(ctl)
+	if ctl.cfg.Load().enabled {
+		t.Error("expected the received settings to apply to controls registered later")
+	}
+}
+
+func TestRemoteConfigLifecycle(t *testing.T) {
+	useLogger(t, new(captureLogger))
+
+	polled := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case polled <- struct{}{}:
+		default:
+		}
+		// hang until the client gives up, which the server notices once the
+		// body is read
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	rc, err := NewRemoteConfig(WithAgentURL(srv.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rc.client.Timeout = time.Minute
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		rc.Start()
+		rc.Start() // no effect
+		<-polled
+		rc.Stop() // cancels the request in flight
+		rc.Stop()
+		rc.Start() // no effect after Stop
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Stop to cancel the request in flight")
+	}
+}
+
+func TestRemoteConfigStopWithoutStart(t *testing.T) {
+	rc, err := NewRemoteConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := time.Now()
+	rc.Stop()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected Stop to return immediately without Start, took %v", d)
+	}
+}
+
+func TestRemoteConfigTracingEnabled(t *testing.T) {
+	useLogger(t, new(captureLogger))
+
+	agent := new(fakeAgent)
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+
+	rc, err := NewRemoteConfig(WithAgentURL(srv.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, ctl := NewServerInterceptorWithControl()
+	rc.Register(ctl)
+
+	agent.set(map[string]string{"ui": `{"lib_config": {"tracing_enabled": false}}`})
+	rc.poll()
+	if ctl.cfg.Load().enabled {
+		t.Error("expected tracing_enabled to disable the interceptor")
+	}
+
+	agent.set(map[string]string{"ui": `{"lib_config": {"tracing_enabled": false, "connect": {"enabled": true}}}`})
+	rc.poll()
+	if !ctl.cfg.Load().enabled {
+		t.Error("expected the connect namespace to take precedence over tracing_enabled")
+	}
+}
+
+func TestRemoteConfigNormalizesKeys(t *testing.T) {
+	settings := remoteSettings{
+		IgnoredMetadata: []string{" Authorization ", ""},
+		UntracedMethods: []string{" /svc.Health/* "},
+	}
+	cfg := newConfig(serverDefaults, anyOptions(settings.options()))
+	if err := cfg.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, ok := cfg.ignoredMetadata["authorization"]; !ok {
+		t.Errorf("expected the ignored metadata to be lowercased and trimmed, got %v", cfg.ignoredMetadata)
+	}
+	if _, ok := cfg.ignoredMetadata[""]; ok {
+		t.Error("expected empty keys to be dropped")
+	}
+	if cfg.isTraced("/svc.Health/Check") {
+		t.Error("expected the untraced methods to be trimmed")
+	}
+}
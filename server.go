@@ -2,6 +2,9 @@ package connect
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"connectrpc.com/connect"
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
@@ -14,45 +17,128 @@ type wrappedStreamingHandlerConn struct {
 	connect.StreamingHandlerConn
 	cfg *config
 	ctx context.Context
+	waf *appsecCall // nil unless AppSec monitors the stream
+	// dsm is the context of the Data Streams pathway propagated by the client
+	// with the first message, nil unless WithDataStreams is enabled
+	dsm context.Context
+	// received reports whether a message was received, see Receive
+	received bool
+	span     *tracer.Span // of the stream, nil unless traced
+	// upstream holds the traces the stream's span links to, see linkUpstream
+	upstream map[[2]uint64]struct{}
 }
 
 func (c *wrappedStreamingHandlerConn) Receive(m any) (err error) {
 	methodName := c.Spec().Procedure
-	_, im := c.cfg.ignoredMethods[methodName]
-	_, um := c.cfg.untracedMethods[methodName]
-	if c.cfg.traceStreamMessages && !im && !um {
-		span, _ := startSpan(
-			c.ctx,
-			c.RequestHeader(),
-			methodName,
-			"connect.message",
-			c.cfg.serviceName,
-			true,
-			c.cfg.startSpanOptions(tracer.Measured())...,
-		)
-		defer func() {
-			withMetadataTags(c.cfg, c.RequestHeader(), span)
-			withRequestTags(c.cfg, m, span)
-			finishWithError(span, err, c.cfg)
-		}()
+	if c.cfg.traceStreamMessages && c.cfg.isTraced(methodName) {
+		if c.cfg.msgContextField != "" {
+			// the parent of the span is carried by the message itself
+			start := time.Now()
+			defer func() { c.finishReceiveSpan(c.startReceivedMessageSpan(m, start), m, err) }()
+		} else {
+			span, _ := c.cfg.startCallSpan(
+				c.ctx,
+				c.Spec(),
+				c.RequestHeader(),
+				nil,
+				"connect.message",
+				true,
+				tracer.Measured(),
+			)
+			defer func() { c.finishReceiveSpan(span, m, err) }()
+		}
+	}
+	if err = c.StreamingHandlerConn.Receive(m); err != nil {
+		return err
 	}
-	err = c.StreamingHandlerConn.Receive(m)
+	if c.dsm != nil {
+		// only the first message was sent with the propagated pathway: the
+		// following ones start pathways of their own, as they do on the client,
+		// instead of reporting the age of the stream as their latency
+		dsm := c.dsm
+		if c.received {
+			dsm = context.Background()
+		}
+		dataStreams.checkpoint(dsm, messageSize(m), dataStreamsEdgeTags("in", methodName)...)
+	}
+	c.received = true
+	err = c.waf.monitorRequest(c.ctx, m)
 	return err
 }
 
+// startReceivedMessageSpan starts the span of the received message m, as
+// configured by WithMessageTraceContext: it joins the trace of the message's
+// producer, or starts a new trace, and links to the stream's span. With
+// WithFanInLinks, it stays in the stream's trace and links to the producer.
+func (c *wrappedStreamingHandlerConn) startReceivedMessageSpan(m any, start time.Time) *tracer.Span {
+	opts := []tracer.StartSpanOption{tracer.Measured(), tracer.StartTime(start)}
+	producer := extractMessage(m, c.cfg.msgContextField)
+	if producer != nil {
+		c.linkUpstream(producer)
+	}
+	if c.cfg.fanInLinks > 0 {
+		if producer != nil {
+			opts = append(opts, tracer.WithSpanLinks([]tracer.SpanLink{spanLink(producer, linkReasonProducer)}))
+		}
+		span, _ := c.cfg.startCallSpan(c.ctx, c.Spec(), c.RequestHeader(), nil, "connect.message", true, opts...)
+		return span
+	}
+	if producer != nil {
+		opts = append(opts, tracer.ChildOf(producer)) //nolint:staticcheck // SA1019: tracer.ChildOf is deprecated, but kept for compatibility
+	}
+	if c.span != nil {
+		opts = append(opts, tracer.WithSpanLinks([]tracer.SpanLink{spanLink(c.span.Context(), linkReasonStream)}))
+	}
+	// hide the stream's span from startSpan so it is not the parent
+	span, _ := c.cfg.startCallSpan(
+		tracer.ContextWithSpan(c.ctx, nil),
+		c.Spec(),
+		c.RequestHeader(),
+		nil,
+		"connect.message",
+		false,
+		opts...,
+	)
+	return span
+}
+
+// linkUpstream links the stream's span to the trace of producer, the context
+// of a received message, unless it already links to it or to
+// WithFanInLinks' maximum number of traces.
+func (c *wrappedStreamingHandlerConn) linkUpstream(producer *tracer.SpanContext) {
+	if c.span == nil || c.cfg.fanInLinks <= 0 {
+		return
+	}
+	trace := [2]uint64{producer.TraceIDUpper(), producer.TraceIDLower()}
+	if _, ok := c.upstream[trace]; ok || len(c.upstream) >= c.cfg.fanInLinks {
+		return
+	}
+	if c.upstream == nil {
+		c.upstream = make(map[[2]uint64]struct{})
+	}
+	c.upstream[trace] = struct{}{}
+	c.span.AddLink(spanLink(producer, linkReasonUpstream))
+}
+
+// finishReceiveSpan tags and finishes the span of the received message m.
+func (c *wrappedStreamingHandlerConn) finishReceiveSpan(span *tracer.Span, m any, err error) {
+	withMetadataTags(c.cfg, c.RequestHeader(), span)
+	withRequestTags(c.cfg, m, span)
+	withRequestFieldTags(c.cfg, m, span)
+	finishWithError(span, err, c.cfg)
+}
+
 func (c *wrappedStreamingHandlerConn) Send(m any) (err error) {
 	methodName := c.Spec().Procedure
-	_, im := c.cfg.ignoredMethods[methodName]
-	_, um := c.cfg.untracedMethods[methodName]
-	if c.cfg.traceStreamMessages && !im && !um {
-		span, _ := startSpan(
+	if c.cfg.traceStreamMessages && c.cfg.isTraced(methodName) {
+		span, _ := c.cfg.startCallSpan(
 			c.ctx,
+			c.Spec(),
 			c.RequestHeader(),
-			methodName,
+			nil,
 			"connect.message",
-			c.cfg.serviceName,
 			true,
-			c.cfg.startSpanOptions(tracer.Measured())...,
+			tracer.Measured(),
 		)
 		defer func() { finishWithError(span, err, c.cfg) }()
 	}
@@ -63,87 +149,174 @@ func (c *wrappedStreamingHandlerConn) Send(m any) (err error) {
 var _ connect.Interceptor = (*serverInterceptor)(nil)
 
 type serverInterceptor struct {
-	cfg *config
+	cfg     *atomic.Pointer[config]
+	startup *sync.Once // see logStartup
 }
 
 func (s serverInterceptor) WrapUnary(unaryFunc connect.UnaryFunc) connect.UnaryFunc {
-	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+	logStartup(s.startup, s)
+	return func(ctx context.Context, req connect.AnyRequest) (resp connect.AnyResponse, err error) {
 		spec := req.Spec()
-		_, im := s.cfg.ignoredMethods[spec.Procedure]
-		_, um := s.cfg.untracedMethods[spec.Procedure]
-		if im || um {
+		cfg := s.cfg.Load().forProcedure(spec)
+		var (
+			span *tracer.Span
+			waf  *appsecCall
+		)
+		if cfg.recoverPanics {
+			// deferred first to also recover untraced calls and start hooks
+			defer func() {
+				if p := recover(); p != nil {
+					resp, err = nil, panicError(p, cfg)
+					waf.finish(&err)
+					if span != nil {
+						for _, fn := range cfg.finishHooks {
+							fn(ctx, span, nil, err)
+						}
+					}
+					finishPanic(span, p, err, cfg)
+				}
+			}()
+		}
+		if !cfg.isTraced(spec.Procedure) {
 			return unaryFunc(ctx, req)
 		}
-		span, ctx := startSpan(
+		span, ctx = cfg.startCallSpan(
 			ctx,
+			spec,
 			req.Header(),
-			spec.Procedure,
-			s.cfg.spanName,
-			s.cfg.serviceName,
+			req,
+			cfg.spanName,
 			true,
-			s.cfg.startSpanOptions(tracer.Measured(),
-				tracer.Tag(ext.SpanKind, ext.SpanKindServer))...,
+			tracer.Measured(),
+			tracer.Tag(ext.SpanKind, ext.SpanKindServer),
 		)
+		ctx = withServerBaggage(cfg, ctx, span)
 		span.SetTag(tagMethodKind, methodKindUnary)
+		withSpecTags(spec, span)
+		withGetTag(req, span)
 		withPeerTags(req.Peer(), span)
-		withMetadataTags(s.cfg, req.Header(), span)
-		withRequestTags(s.cfg, req.Any(), span)
-		resp, err := unaryFunc(ctx, req)
-		finishWithError(span, err, s.cfg)
+		withMetadataTags(cfg, req.Header(), span)
+		withRequestTags(cfg, req.Any(), span)
+		withRequestFieldTags(cfg, req.Any(), span)
+		for _, fn := range cfg.startHooks {
+			fn(ctx, span, req)
+		}
+		waf, ctx = startAppSec(ctx, cfg, span, spec.Procedure, req.Header(), req.Peer())
+		// a blocked request or user fails the call without reaching the handler
+		if err = waf.monitorRequest(ctx, req.Any()); err == nil {
+			if err = setExtractedUser(ctx, cfg, req); err == nil {
+				resp, err = unaryFunc(ctx, req)
+			}
+		}
+		waf.extractSchemas(spec.Procedure, req, resp, err)
+		if waf.finish(&err) {
+			resp = nil
+		}
+		for _, fn := range cfg.finishHooks {
+			fn(ctx, span, resp, err)
+		}
+		finishWithError(span, err, cfg)
 		return resp, err
 	}
 }
 
 func (s serverInterceptor) WrapStreamingClient(clientFunc connect.StreamingClientFunc) connect.StreamingClientFunc {
+	logStartup(s.startup, s)
 	return clientFunc
 }
 
 func (s serverInterceptor) WrapStreamingHandler(handlerFunc connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	logStartup(s.startup, s)
 	return func(ctx context.Context, conn connect.StreamingHandlerConn) (err error) {
 		spec := conn.Spec()
-		_, im := s.cfg.ignoredMethods[spec.Procedure]
-		_, um := s.cfg.untracedMethods[spec.Procedure]
-		if s.cfg.traceStreamCalls && !im && !um {
-			var span *tracer.Span
-			span, ctx = startSpan(
+		cfg := s.cfg.Load().forProcedure(spec)
+		var (
+			span *tracer.Span
+			waf  *appsecCall
+		)
+		// deferred first to also recover start hooks
+		defer func() {
+			var p any
+			if cfg.recoverPanics {
+				if p = recover(); p != nil {
+					err = panicError(p, cfg)
+				}
+			}
+			waf.finish(&err)
+			if span != nil {
+				for _, fn := range cfg.streamFinishHooks {
+					fn(ctx, span, conn, err)
+				}
+			}
+			if p != nil {
+				finishPanic(span, p, err, cfg)
+			} else if span != nil {
+				finishWithError(span, err, cfg)
+			}
+		}()
+		if cfg.traceStreamCalls && cfg.isTraced(spec.Procedure) {
+			span, ctx = cfg.startCallSpan(
 				ctx,
+				spec,
 				conn.RequestHeader(),
-				spec.Procedure,
-				s.cfg.spanName,
-				s.cfg.serviceName,
+				nil,
+				cfg.spanName,
 				true,
-				s.cfg.startSpanOptions(tracer.Measured(),
-					tracer.Tag(ext.SpanKind, ext.SpanKindServer))...,
+				tracer.Measured(),
+				tracer.Tag(ext.SpanKind, ext.SpanKindServer),
 			)
+			ctx = withServerBaggage(cfg, ctx, span)
+			withSpecTags(spec, span)
 			withPeerTags(conn.Peer(), span)
-			withMetadataTags(s.cfg, conn.RequestHeader(), span)
-			switch conn.Spec().StreamType {
-			case connect.StreamTypeBidi:
-				span.SetTag(tagMethodKind, methodKindBidiStream)
-			case connect.StreamTypeServer:
-				span.SetTag(tagMethodKind, methodKindServerStream)
-			case connect.StreamTypeClient:
-				span.SetTag(tagMethodKind, methodKindClientStream)
+			withMetadataTags(cfg, conn.RequestHeader(), span)
+			span.SetTag(tagMethodKind, methodKind(spec.StreamType))
+			for _, fn := range cfg.streamStartHooks {
+				fn(ctx, span, conn)
 			}
-			defer func() { finishWithError(span, err, s.cfg) }()
+		}
+		if span != nil {
+			waf, ctx = startAppSec(ctx, cfg, span, spec.Procedure, conn.RequestHeader(), conn.Peer())
 		}
 
+		// a request blocked by its headers or peer never reaches the handler
+		if waf.blocked(&err) {
+			return err
+		}
 		// call the original handler with a new stream, which traces each send
-		// and recv if message tracing is enabled
-		err = handlerFunc(ctx, &wrappedStreamingHandlerConn{
+		// and recv if message tracing is enabled, and monitors and checkpoints
+		// each received message if AppSec and Data Streams are enabled
+		wrapped := &wrappedStreamingHandlerConn{
 			StreamingHandlerConn: conn,
-			cfg:                  s.cfg,
+			cfg:                  cfg,
 			ctx:                  ctx,
-		})
+			waf:                  waf,
+			span:                 span,
+		}
+		if cfg.dataStreams && cfg.isTraced(spec.Procedure) {
+			wrapped.dsm = dataStreams.extract(ctx, conn.RequestHeader())
+		}
+		err = handlerFunc(ctx, wrapped)
 		return err
 	}
 }
 
-func NewServerInterceptor(opts ...Option) connect.Interceptor {
-	cfg := new(config)
-	serverDefaults(cfg)
-	for _, opt := range opts {
-		opt(cfg)
+// NewServerInterceptor returns a connect.Interceptor which traces handlers.
+// Invalid options are ignored; use NewServerInterceptorE to detect them.
+func NewServerInterceptor(opts ...ServerOption) connect.Interceptor {
+	cfg := new(atomic.Pointer[config])
+	cfg.Store(newConfig(serverDefaults, anyOptions(opts)))
+	return &serverInterceptor{cfg: cfg, startup: new(sync.Once)}
+}
+
+// NewServerInterceptorE is like NewServerInterceptor, but returns an error
+// joining every invalid or conflicting option instead of ignoring them, such as
+// WithAnalyticsRate(1.5) or empty WithIgnoredMetadata keys.
+func NewServerInterceptorE(opts ...ServerOption) (connect.Interceptor, error) {
+	c := newConfig(serverDefaults, anyOptions(opts))
+	if err := c.validate(); err != nil {
+		return nil, err
 	}
-	return &serverInterceptor{cfg: cfg}
+	cfg := new(atomic.Pointer[config])
+	cfg.Store(c)
+	return &serverInterceptor{cfg: cfg, startup: new(sync.Once)}, nil
 }
@@ -10,7 +10,7 @@ import (
 func TestNewServerInterceptor(t *testing.T) {
 	tests := []struct {
 		name string
-		opts []Option
+		opts []ServerOption
 	}{
 		{
 			name: "default options",
@@ -18,11 +18,11 @@ func TestNewServerInterceptor(t *testing.T) {
 		},
 		{
 			name: "with service name",
-			opts: []Option{WithService("test-service")},
+			opts: []ServerOption{WithService("test-service")},
 		},
 		{
 			name: "with multiple options",
-			opts: []Option{
+			opts: []ServerOption{
 				WithService("test-service"),
 				WithStreamCalls(false),
 				NoDebugStack(),
@@ -64,7 +64,7 @@ func TestServerInterceptor_WrapUnary(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var options []Option
+			var options []ServerOption
 			if len(tt.ignoredMethods) > 0 {
 				options = append(options, WithIgnoredMethods(tt.ignoredMethods...))
 			}
@@ -110,7 +110,7 @@ func TestServerInterceptor_WrapStreamingHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var options []Option
+			var options []ServerOption
 			options = append(options, WithStreamCalls(tt.traceStream))
 			if len(tt.ignoredMethods) > 0 {
 				options = append(options, WithIgnoredMethods(tt.ignoredMethods...))
@@ -6,8 +6,20 @@ const (
 	tagCode           = "connect.code"
 	tagMetadataPrefix = "connect.metadata."
 	tagRequest        = "connect.request"
+	tagRequestField   = "connect.request.field."
 	tagProtocol       = "connect.protocol"
 	tagPeerAddr       = "connect.peer.addr"
+	tagRequestType    = "connect.request.type"
+	tagResponseType   = "connect.response.type"
+	tagIdempotency    = "connect.idempotency"
+	tagGet            = "connect.get"
+	tagBaggagePrefix  = "baggage."
+)
+
+const (
+	idempotencyUnknown       = "unknown"
+	idempotencyNoSideEffects = "no_side_effects"
+	idempotencyIdempotent    = "idempotent"
 )
 
 const (
@@ -27,4 +39,8 @@ const (
 	// has no explicit OK code, so this mirrors gRPC's codes.OK in connect's
 	// lowercase code style.
 	codeOK = "ok"
+
+	// errorTypePanic is the error.type tag value for spans finished after
+	// recovering from a panic.
+	errorTypePanic = "panic"
 )
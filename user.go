@@ -0,0 +1,86 @@
+package connect
+
+import (
+	"context"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/appsec"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+)
+
+const (
+	tagUserID        = "usr.id"
+	tagUserLogin     = "usr.login"
+	tagUserOrg       = "usr.org"
+	tagUserEmail     = "usr.email"
+	tagUserName      = "usr.name"
+	tagUserRole      = "usr.role"
+	tagUserScope     = "usr.scope"
+	tagUserSessionID = "usr.session_id"
+)
+
+// SetUser associates the authenticated user id with the connect server span
+// found in ctx, setting usr.id and the usr.* tags selected by opts such as
+// tracer.WithUserEmail or tracer.WithUserSessionID. It also calls
+// appsec.SetUser, which sets the same tags on the trace's root span and checks
+// the user against the denylist when AppSec is enabled. When the user is
+// blocked, SetUser returns a connect.CodePermissionDenied error which the
+// handler must return immediately.
+func SetUser(ctx context.Context, id string, opts ...tracer.UserMonitoringOption) error {
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		withUserTags(span, id, opts)
+	}
+	if err := appsec.SetUser(ctx, id, opts...); err != nil {
+		return connect.NewError(connect.CodePermissionDenied, err)
+	}
+	return nil
+}
+
+// withUserTags sets the user tags described by id and opts on span, as
+// tracer.SetUser does on the root span.
+func withUserTags(span *tracer.Span, id string, opts []tracer.UserMonitoringOption) {
+	cfg := tracer.UserMonitoringConfig{Metadata: map[string]string{}}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	span.SetTag(tagUserID, id)
+	for tag, value := range map[string]string{
+		tagUserLogin:     cfg.Login,
+		tagUserOrg:       cfg.Org,
+		tagUserEmail:     cfg.Email,
+		tagUserName:      cfg.Name,
+		tagUserRole:      cfg.Role,
+		tagUserScope:     cfg.Scope,
+		tagUserSessionID: cfg.SessionID,
+	} {
+		if value != "" {
+			span.SetTag(tag, value)
+		}
+	}
+	for k, v := range cfg.Metadata {
+		span.SetTag("usr."+k, v)
+	}
+}
+
+// setExtractedUser calls SetUser with the user returned by the function given to
+// WithUserExtractor, if any.
+func setExtractedUser(ctx context.Context, cfg *config, req connect.AnyRequest) error {
+	if cfg.userExtractor == nil {
+		return nil
+	}
+	if id := cfg.userExtractor(req); id != "" {
+		return SetUser(ctx, id)
+	}
+	return nil
+}
+
+// WithUserExtractor sets a function returning the ID of the user authenticated
+// by a unary request, e.g. from its authorization header, which the server
+// interceptor passes to SetUser before calling the handler. When fn returns an
+// empty string the user is left unset. When the user is blocked, the handler is
+// not called and the call fails with connect.CodePermissionDenied.
+func WithUserExtractor(fn func(req connect.AnyRequest) string) ServerOption {
+	return serverOption("WithUserExtractor", func(cfg *config) {
+		cfg.userExtractor = fn
+	})
+}
@@ -0,0 +1,79 @@
+package connect
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestSetUser(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	call := NewServerInterceptor().WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if err := SetUser(ctx, "u1",
+			tracer.WithUserEmail("u1@example.com"),
+			tracer.WithUserSessionID("s1"),
+			tracer.WithUserMetadata("plan", "pro"),
+		); err != nil {
+			return nil, err
+		}
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+	if _, err := call(context.Background(), connect.NewRequest(&wrapperspb.StringValue{})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	for tag, want := range map[string]string{
+		tagUserID:        "u1",
+		tagUserEmail:     "u1@example.com",
+		tagUserSessionID: "s1",
+		"usr.plan":       "pro",
+	} {
+		if got := spans[0].Tag(tag); got != want {
+			t.Errorf("expected %s=%s, got %v", tag, want, got)
+		}
+	}
+	if got := spans[0].Tag(tagUserName); got != nil {
+		t.Errorf("expected unset user fields not to be tagged, got %v", got)
+	}
+}
+
+func TestUserExtractor(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	interceptor := NewServerInterceptor(WithUserExtractor(func(req connect.AnyRequest) string {
+		return strings.TrimPrefix(req.Header().Get("Authorization"), "User ")
+	}))
+	call := interceptor.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&wrapperspb.StringValue{}), nil
+	})
+	for _, auth := range []string{"User u2", ""} {
+		req := connect.NewRequest(&wrapperspb.StringValue{})
+		req.Header().Set("Authorization", auth)
+		if _, err := call(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if got := spans[0].Tag(tagUserID); got != "u2" {
+		t.Errorf("expected usr.id u2, got %v", got)
+	}
+	if got := spans[1].Tag(tagUserID); got != nil {
+		t.Errorf("expected no usr.id without a user, got %v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package connect
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// validate returns the problems found in cfg joined in a single error: invalid
+// option values, recorded by the options themselves, and options which
+// conflict with each other. The procedure options are validated as applied on
+// top of cfg.
+func (cfg *config) validate() error {
+	var errs []error
+	seen := map[string]bool{}
+	add := func(prefix string, err error) {
+		if msg := prefix + err.Error(); !seen[msg] {
+			seen[msg] = true
+			errs = append(errs, errors.New(msg))
+		}
+	}
+	for _, err := range cfg.validateOne() {
+		add("", err)
+	}
+	for _, po := range cfg.procedureOpts {
+		c := cfg.clone()
+		c.errs = nil
+		for _, opt := range po.opts {
+			c.apply(opt)
+		}
+		for _, err := range c.validateOne() {
+			if !seen[err.Error()] {
+				add(fmt.Sprintf("WithProcedureOptions(%q): ", po.procedure), err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (cfg *config) validateOne() []error {
+	errs := slices.Clone(cfg.errs)
+	if cfg.repanic && !cfg.recoverPanics {
+		errs = append(errs, errors.New("WithRepanic requires WithPanicRecovery"))
+	}
+	if cfg.msgContextField != "" && !cfg.traceStreamMessages {
+		errs = append(errs, errors.New("WithMessageTraceContext requires WithStreamMessages"))
+	}
+	if cfg.fanInLinks > 0 && cfg.msgContextField == "" {
+		errs = append(errs, errors.New("WithFanInLinks requires WithMessageTraceContext"))
+	}
+	for _, m := range slices.Sorted(maps.Keys(cfg.tracedMethods)) {
+		_, untraced := cfg.untracedMethods[m]
+		_, ignored := cfg.ignoredMethods[m]
+		if untraced || ignored {
+			errs = append(errs, fmt.Errorf("method %q is both traced and untraced", m))
+		}
+	}
+	return errs
+}
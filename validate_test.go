@@ -0,0 +1,146 @@
+package connect
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewInterceptorE(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []ServerOption
+		clientOpts []ClientOption // tests NewClientInterceptorE when set
+		errs       []string
+	}{
+		{
+			name: "valid options",
+			opts: []ServerOption{WithService("svc"), WithAnalyticsRate(0.5), WithIgnoredMetadata("authorization")},
+		},
+		{
+			name: "analytics rate",
+			opts: []ServerOption{WithAnalyticsRate(1.5)},
+			errs: []string{"WithAnalyticsRate: rate 1.5 is outside [0, 1]"},
+		},
+		{
+			name: "mixed-case metadata",
+			opts: []ServerOption{WithIgnoredMetadata("Authorization", " X-Api-Key ")},
+		},
+		{
+			name: "empty metadata key",
+			opts: []ServerOption{WithIgnoredMetadata("authorization", " ")},
+			errs: []string{`WithIgnoredMetadata: empty key " "`},
+		},
+		{
+			name: "nil regexp",
+			opts: []ServerOption{WithUntracedMethodsRegexp(regexp.MustCompile("^/a"), nil)},
+			errs: []string{"WithUntracedMethodsRegexp: nil regular expression"},
+		},
+		{
+			name: "repanic without recovery",
+			opts: []ServerOption{WithRepanic(true)},
+			errs: []string{"WithRepanic requires WithPanicRecovery"},
+		},
+		{
+			name:       "client panic recovery",
+			clientOpts: []ClientOption{WithProcedureOptions("/a.S/*", WithPanicRecovery(nil), WithStreamStartHook(nil))},
+			errs: []string{
+				`WithProcedureOptions("/a.S/*"): WithPanicRecovery only applies to server interceptors`,
+				`WithProcedureOptions("/a.S/*"): WithStreamStartHook only applies to server interceptors`,
+			},
+		},
+		{
+			name: "server context tags baggage",
+			opts: []ServerOption{WithProcedureOptions("/a.S/*", WithContextTagsBaggage(true))},
+			errs: []string{`WithProcedureOptions("/a.S/*"): WithContextTagsBaggage only applies to client interceptors`},
+		},
+		{
+			name: "message trace context without message spans",
+			opts: []ServerOption{WithStreamMessages(false), WithMessageTraceContext("trace_context")},
+			errs: []string{"WithMessageTraceContext requires WithStreamMessages"},
+		},
+		{
+			name: "empty message trace context field",
+			opts: []ServerOption{WithMessageTraceContext("")},
+			errs: []string{"WithMessageTraceContext: empty field name"},
+		},
+		{
+			name: "fan-in links without message trace context",
+			opts: []ServerOption{WithFanInLinks(8)},
+			errs: []string{"WithFanInLinks requires WithMessageTraceContext"},
+		},
+		{
+			name: "negative fan-in links",
+			opts: []ServerOption{WithFanInLinks(-1)},
+			errs: []string{"WithFanInLinks: negative maximum -1"},
+		},
+		{
+			name: "traced and untraced",
+			opts: []ServerOption{WithTracedMethods("/a.S/M", "/a.S/N"), WithUntracedMethods("/a.S/M")},
+			errs: []string{`method "/a.S/M" is both traced and untraced`},
+		},
+		{
+			name: "procedure options",
+			opts: []ServerOption{
+				WithAnalyticsRate(-1),
+				WithProcedureOptions("/a.S/*", WithAnalyticsRate(-1), WithTracedMethods("/a.S/M"), WithUntracedMethods("/a.S/M")),
+			},
+			errs: []string{
+				"WithAnalyticsRate: rate -1 is outside [0, 1]",
+				`WithProcedureOptions("/a.S/*"): method "/a.S/M" is both traced and untraced`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor, err := NewServerInterceptorE(tt.opts...)
+			if tt.clientOpts != nil {
+				interceptor, err = NewClientInterceptorE(tt.clientOpts...)
+			}
+			if len(tt.errs) == 0 {
+				if err != nil || interceptor == nil {
+					t.Fatalf("expected an interceptor, got error %v", err)
+				}
+				return
+			}
+			if err == nil || interceptor != nil {
+				t.Fatalf("expected an error, got interceptor %v", interceptor)
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.errs) {
+				t.Fatalf("expected %d errors, got %q", len(tt.errs), lines)
+			}
+			for i, want := range tt.errs {
+				if !strings.Contains(lines[i], want) {
+					t.Errorf("expected error %d to contain %q, got %q", i, want, lines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewInterceptorLenient(t *testing.T) {
+	if NewServerInterceptor(WithAnalyticsRate(1.5), WithRepanic(true)) == nil {
+		t.Error("expected the lenient constructor to ignore invalid options")
+	}
+	if NewClientInterceptor(WithAnalyticsRate(-1)) == nil {
+		t.Error("expected the lenient constructor to ignore invalid options")
+	}
+}
+
+func TestNewInterceptorSideOptions(t *testing.T) {
+	i := NewInterceptor(WithPanicRecovery(nil), WithContextTagsBaggage(true)).(*interceptor)
+	server, client := i.server.cfg.Load(), i.client.cfg.Load()
+	if err := server.validate(); err != nil {
+		t.Errorf("unexpected server error: %v", err)
+	}
+	if err := client.validate(); err != nil {
+		t.Errorf("unexpected client error: %v", err)
+	}
+	if !server.recoverPanics || server.contextTagsBaggage {
+		t.Error("expected server options to only apply to the server")
+	}
+	if client.recoverPanics || !client.contextTagsBaggage {
+		t.Error("expected client options to only apply to the client")
+	}
+}